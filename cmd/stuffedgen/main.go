@@ -0,0 +1,62 @@
+// Command stuffedgen generates a Go source file that embeds a static lookup
+// table of stuffed records.  It reads a text file in which each line is a key,
+// followed by a separator (a tab, by default), followed by a value.  The lines
+// don't need to be sorted, but each key can only appear once.  For example:
+//
+//	//go:generate stuffedgen -package mime -func Lookup -o mime_table.go mime.txt
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/dcreager/stuffed-records-go/stuffedgen"
+)
+
+func main() {
+	var config stuffedgen.Config
+	flag.StringVar(&config.Package, "package", os.Getenv("GOPACKAGE"), "name of the generated file's package")
+	flag.StringVar(&config.Func, "func", "Lookup", "name of the generated lookup function")
+	flag.StringVar(&config.Var, "var", "", "name of the generated table variable")
+	separator := flag.String("sep", "\t", "byte that separates each key from its value")
+	output := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+	if flag.NArg() != 1 || len(*separator) != 1 || config.Package == "" {
+		fmt.Fprintln(os.Stderr, "usage: stuffedgen -package name [-func name] [-var name] [-sep byte] [-o output] input")
+		os.Exit(2)
+	}
+	config.Separator = (*separator)[0]
+
+	if err := run(flag.Arg(0), *output, config); err != nil {
+		fmt.Fprintln(os.Stderr, "stuffedgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output string, config stuffedgen.Config) error {
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var rb stuffed.RecordBuilder
+	if err := stuffed.IngestLines(file, &rb, 0); err != nil {
+		return err
+	}
+	rb.Sort()
+
+	var generated bytes.Buffer
+	if err := stuffedgen.Generate(&generated, &rb, config); err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(generated.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(output, generated.Bytes(), 0644)
+}
@@ -0,0 +1,145 @@
+// Package cobs provides a Go implementation of classic Consistent Overhead Byte
+// Stuffing (COBS), which uses a single `0x00` byte as the record delimiter.  It
+// has the same API shape as the stuffed package, and can convert streams
+// between the two framings, so that you can bridge devices that speak COBS with
+// storage that uses stuffed records.
+package cobs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+const delimiter = 0x00
+
+// maxCode is the code byte for a block of 254 non-zero bytes that isn't
+// followed by an implicit zero.
+const maxCode = 0xff
+
+var (
+	// InvalidCode is the error that is returned when a COBS record contains a
+	// zero code byte.
+	InvalidCode = errors.New("Invalid COBS code byte")
+)
+
+// Encode writes a binary record into an output buffer using the COBS encoding.
+// This guarantees that the content that we write does not contain any zero
+// bytes.  (We do _not_ write a trailing delimiter; it is your responsibility to
+// write this in between records using EncodeDelimiter.)
+func Encode(record []byte, buf *bytes.Buffer) {
+	buf.Grow(len(record) + len(record)/(maxCode-1) + 1)
+	for {
+		// Each block consists of a code byte, followed by up to 254 non-zero
+		// bytes.
+		runSize := bytes.IndexByte(record, delimiter)
+		if runSize == -1 {
+			runSize = len(record)
+		}
+		if runSize >= maxCode-1 {
+			buf.WriteByte(maxCode)
+			buf.Write(record[:maxCode-1])
+			record = record[maxCode-1:]
+			// A full block at the very end of the record doesn't need a
+			// final empty block after it.
+			if len(record) == 0 {
+				return
+			}
+			continue
+		}
+
+		buf.WriteByte(byte(runSize + 1))
+		buf.Write(record[:runSize])
+		record = record[runSize:]
+		if len(record) == 0 {
+			return
+		}
+		// record should start with a zero, so skip over it.
+		record = record[1:]
+	}
+}
+
+// EncodeDelimiter writes the COBS delimiter to an output buffer.  You should
+// use this to separate records in your output stream.
+func EncodeDelimiter(buf *bytes.Buffer) {
+	buf.WriteByte(delimiter)
+}
+
+// FindDelimiter returns the index of the first occurrence of the COBS
+// delimiter in buf, or -1 if it doesn't occur.
+func FindDelimiter(buf []byte) int {
+	return bytes.IndexByte(buf, delimiter)
+}
+
+// Decode reads a binary record from an input buffer using the COBS encoding.
+// You must ensure that encoded does not contain any zero bytes.  (FindDelimiter
+// can help you find the bounds of an encoded record before decoding it.)
+func Decode(encoded []byte, record *bytes.Buffer) error {
+	if len(encoded) == 0 {
+		return io.EOF
+	}
+	for len(encoded) > 0 {
+		code := int(encoded[0])
+		encoded = encoded[1:]
+		if code == delimiter {
+			return InvalidCode
+		}
+		if len(encoded) < code-1 {
+			return io.EOF
+		}
+		record.Write(encoded[:code-1])
+		encoded = encoded[code-1:]
+		// Every block except a full one implies a zero, unless it's the last
+		// block in the record.
+		if code < maxCode && len(encoded) > 0 {
+			record.WriteByte(0)
+		}
+	}
+	return nil
+}
+
+// Scanner iterates through a buffer containing zero or more delimited COBS
+// records.  Just like stuffed.Scanner, it skips over any empty space between
+// consecutive delimiters.
+type Scanner struct {
+	record []byte
+	list   []byte
+}
+
+// Reset updates a Scanner to read from a new buffer of delimited COBS records.
+func (s *Scanner) Reset(encodedList []byte) {
+	s.record = nil
+	s.list = encodedList
+}
+
+// Next returns whether there is a next COBS record in the underlying buffer.
+// If this returns true, you can use Encoded and Decode to access that record.
+func (s *Scanner) Next() bool {
+	// Skip over any leading delimiters.
+	for len(s.list) > 0 && s.list[0] == delimiter {
+		s.list = s.list[1:]
+	}
+	if len(s.list) == 0 {
+		return false
+	}
+	index := FindDelimiter(s.list)
+	if index == -1 {
+		s.record = s.list
+		s.list = nil
+	} else {
+		s.record = s.list[:index]
+		s.list = s.list[index:]
+	}
+	return true
+}
+
+// Encoded returns the portion of the underlying buffer that contains the
+// encoded content of the current COBS record.
+func (s *Scanner) Encoded() []byte {
+	return s.record
+}
+
+// Decode reads the current COBS record and decodes it into an output Buffer.
+func (s *Scanner) Decode(decoded *bytes.Buffer) error {
+	return Decode(s.record, decoded)
+}
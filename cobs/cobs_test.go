@@ -0,0 +1,118 @@
+package cobs_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/cobs"
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func byteRange(from, to int) string {
+	var buf bytes.Buffer
+	for b := from; b <= to; b++ {
+		buf.WriteByte(byte(b))
+	}
+	return buf.String()
+}
+
+type testCase struct {
+	decoded string
+	encoded string
+}
+
+// These are the examples from the Wikipedia article on COBS.
+var testCases = []testCase{
+	{"", "\x01"},
+	{"\x00", "\x01\x01"},
+	{"\x00\x00", "\x01\x01\x01"},
+	{"\x00\x11\x00", "\x01\x02\x11\x01"},
+	{"\x11\x22\x00\x33", "\x03\x11\x22\x02\x33"},
+	{"\x11\x22\x33\x44", "\x05\x11\x22\x33\x44"},
+	{"\x11\x00\x00\x00", "\x02\x11\x01\x01\x01"},
+	{byteRange(0x01, 0xfe), "\xff" + byteRange(0x01, 0xfe)},
+	{byteRange(0x00, 0xfe), "\x01\xff" + byteRange(0x01, 0xfe)},
+	{byteRange(0x01, 0xff), "\xff" + byteRange(0x01, 0xfe) + "\x02\xff"},
+	{byteRange(0x02, 0xff) + "\x00", "\xff" + byteRange(0x02, 0xff) + "\x01\x01"},
+	{byteRange(0x03, 0xff) + "\x00\x01", "\xfe" + byteRange(0x03, 0xff) + "\x02\x01"},
+}
+
+func testInputs() []string {
+	var result []string
+	for _, tc := range testCases {
+		result = append(result, tc.decoded)
+	}
+	return result
+}
+
+func TestEncode(t *testing.T) {
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		cobs.Encode([]byte(tc.decoded), &buf)
+		assert.Equal(t, tc.encoded, buf.String())
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		require.NoError(t, cobs.Decode([]byte(tc.encoded), &buf))
+		assert.Equal(t, tc.decoded, buf.String())
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, io.EOF, cobs.Decode(nil, &buf))
+	assert.Equal(t, io.EOF, cobs.Decode([]byte("\x03a"), &buf))
+	assert.Equal(t, cobs.InvalidCode, cobs.Decode([]byte("\x02a\x00"), &buf))
+}
+
+func encodeList(inputList []string) []byte {
+	var encoded bytes.Buffer
+	for _, input := range inputList {
+		cobs.Encode([]byte(input), &encoded)
+		cobs.EncodeDelimiter(&encoded)
+	}
+	return encoded.Bytes()
+}
+
+func TestScanner(t *testing.T) {
+	inputList := testInputs()
+	encoded := append([]byte{0x00}, encodeList(inputList)...)
+	var s cobs.Scanner
+	s.Reset(encoded)
+	actual := []string{}
+	for s.Next() {
+		assert.Equal(t, -1, cobs.FindDelimiter(s.Encoded()))
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputList, actual)
+}
+
+func TestConvert(t *testing.T) {
+	inputList := testInputs()
+	encoded := encodeList(inputList)
+
+	var stuffedList bytes.Buffer
+	require.NoError(t, cobs.ToStuffed(bytes.NewReader(encoded), &stuffedList))
+	var s stuffed.Scanner
+	s.Reset(stuffedList.Bytes())
+	actual := []string{}
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputList, actual)
+
+	var roundTripped bytes.Buffer
+	require.NoError(t, cobs.FromStuffed(&stuffedList, &roundTripped))
+	assert.Equal(t, encoded, roundTripped.Bytes())
+
+	err := cobs.ToStuffed(bytes.NewReader([]byte("\x03a\x00")), &stuffedList)
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,76 @@
+package cobs
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// maxEncodedRecord is the largest encoded COBS record that we'll buffer.  This
+// is comfortably larger than the encoding of a record of
+// stuffed.DefaultMaxRecordSize bytes.
+const maxEncodedRecord = 2 * stuffed.DefaultMaxRecordSize
+
+// SplitRecords is a bufio.SplitFunc that splits a stream of delimited COBS
+// records into individual records, just like stuffed.SplitRecords.
+func SplitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	start := 0
+	for start < len(data) && data[start] == delimiter {
+		start++
+	}
+	if index := FindDelimiter(data[start:]); index != -1 {
+		end := start + index
+		return end, data[start:end], nil
+	}
+	if atEOF {
+		if start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return len(data), nil, nil
+	}
+	return start, nil, nil
+}
+
+// ToStuffed converts a stream of delimited COBS records into a stream of
+// delimited stuffed records.
+func ToStuffed(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEncodedRecord)
+	scanner.Split(SplitRecords)
+	var decoded, encoded bytes.Buffer
+	for scanner.Scan() {
+		decoded.Reset()
+		if err := Decode(scanner.Bytes(), &decoded); err != nil {
+			return err
+		}
+		encoded.Reset()
+		stuffed.Encode(decoded.Bytes(), &encoded)
+		stuffed.EncodeDelimiter(&encoded)
+		if _, err := w.Write(encoded.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// FromStuffed converts a stream of delimited stuffed records into a stream of
+// delimited COBS records.
+func FromStuffed(r io.Reader, w io.Writer) error {
+	reader := stuffed.NewReader(r)
+	var decoded, encoded bytes.Buffer
+	for reader.Next() {
+		decoded.Reset()
+		if err := reader.Decode(&decoded); err != nil {
+			return err
+		}
+		encoded.Reset()
+		Encode(decoded.Bytes(), &encoded)
+		EncodeDelimiter(&encoded)
+		if _, err := w.Write(encoded.Bytes()); err != nil {
+			return err
+		}
+	}
+	return reader.Err()
+}
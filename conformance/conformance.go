@@ -0,0 +1,252 @@
+// Package conformance implements a simple request/response protocol for
+// testing other implementations of the stuffed records encoding against this
+// one.  You can serve the protocol over any stream, such as stdin and stdout
+// of a subprocess, or a TCP connection.
+//
+// Each request consists of a one-byte operation, a uvarint payload length, and
+// the payload:
+//
+//   - 'E' asks us to encode the payload as a single stuffed record.  The
+//     response contains the canonical encoding, without a trailing delimiter.
+//   - 'D' asks us to decode the payload, which must be a single encoded
+//     stuffed record, without any delimiters.  The response contains the
+//     decoded content.
+//
+// Each response consists of a one-byte status, a uvarint payload length, and
+// the payload.  A status of 'O' means that the request succeeded, and the
+// payload is the result.  A status of 'X' means that the request failed, and
+// the payload is one of the error codes below.
+//
+// The server responds to requests in order, and stops when the request stream
+// ends.  A malformed request (an unknown operation, or a truncated or oversized
+// payload) ends the session with an error, since we can't resynchronize with
+// the request stream.
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// Operations and statuses.
+const (
+	OpEncode    byte = 'E'
+	OpDecode    byte = 'D'
+	StatusOK    byte = 'O'
+	StatusError byte = 'X'
+)
+
+// Error codes that are returned in the payload of a failed response.  They're
+// meant to be stable across implementations, unlike Go's error messages.
+const (
+	// CodeTruncated means that the encoded record ended in the middle of a
+	// run header or run.
+	CodeTruncated = "truncated"
+	// CodeInvalidRunLength means that a run header was out of range.
+	CodeInvalidRunLength = "invalid-run-length"
+	// CodeEmbeddedDelimiter means that the encoded record contained the
+	// delimiter sequence.
+	CodeEmbeddedDelimiter = "embedded-delimiter"
+)
+
+// MaxPayload is the largest request payload that we'll accept.
+const MaxPayload = 2 * stuffed.DefaultMaxRecordSize
+
+var (
+	// UnknownOperation is the error that is returned when a request contains
+	// an operation that we don't recognize.
+	UnknownOperation = errors.New("Unknown conformance operation")
+
+	// UnknownStatus is the error that is returned when a response contains a
+	// status that we don't recognize.
+	UnknownStatus = errors.New("Unknown conformance status")
+)
+
+// Serve reads requests from r and writes responses to w until r reaches EOF.
+// It returns nil if the request stream ends cleanly between two requests.
+func Serve(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+	var payload []byte
+	var result bytes.Buffer
+	for {
+		op, err := reader.ReadByte()
+		if err == io.EOF {
+			return writer.Flush()
+		} else if err != nil {
+			return err
+		}
+		payload, err = readFrame(reader, payload)
+		if err != nil {
+			return err
+		}
+
+		result.Reset()
+		var code string
+		switch op {
+		case OpEncode:
+			stuffed.Encode(payload, &result)
+		case OpDecode:
+			code = decode(payload, &result)
+		default:
+			return UnknownOperation
+		}
+
+		if code != "" {
+			err = writeFrame(writer, StatusError, []byte(code))
+		} else {
+			err = writeFrame(writer, StatusOK, result.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+		// Flush after every response, so that clients can wait for each
+		// response before sending their next request.
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// decode decodes an encoded record, returning an error code if it's invalid.
+func decode(encoded []byte, result *bytes.Buffer) string {
+	if stuffed.FindDelimiter(encoded) != -1 {
+		return CodeEmbeddedDelimiter
+	}
+	switch err := stuffed.Decode(encoded, result); err {
+	case nil:
+		return ""
+	case io.EOF:
+		return CodeTruncated
+	case stuffed.InvalidRunLength:
+		return CodeInvalidRunLength
+	default:
+		return err.Error()
+	}
+}
+
+// ServeListener accepts connections from a listener, serving the protocol on
+// each one in its own goroutine.  It returns when the listener fails, such as
+// when it's closed.
+func ServeListener(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			Serve(conn, conn)
+		}()
+	}
+}
+
+// readFrame reads a uvarint length and that many bytes of payload, reusing buf
+// if it's large enough.
+func readFrame(reader *bufio.Reader, buf []byte) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if length > MaxPayload {
+		return nil, &stuffed.ErrRecordTooLarge{Size: int(length), Limit: MaxPayload}
+	}
+	if cap(buf) < int(length) {
+		buf = make([]byte, length)
+	}
+	buf = buf[:length]
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeFrame writes a one-byte tag, and a uvarint-length-prefixed payload.
+func writeFrame(w io.Writer, tag byte, payload []byte) error {
+	var header [1 + binary.MaxVarintLen64]byte
+	header[0] = tag
+	n := binary.PutUvarint(header[1:], uint64(len(payload)))
+	if _, err := w.Write(header[:1+n]); err != nil {
+		return err
+	}
+	// Some synchronous streams (such as net.Pipe) block on an empty write
+	// until the other side reads.
+	if len(payload) == 0 {
+		return nil
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// Client sends conformance requests to a server, such as an implementation
+// that you want to test.
+type Client struct {
+	reader  *bufio.Reader
+	writer  io.Writer
+	payload []byte
+}
+
+// NewClient creates a new Client that sends requests to w and reads responses
+// from r.
+func NewClient(r io.Reader, w io.Writer) *Client {
+	return &Client{reader: bufio.NewReader(r), writer: w}
+}
+
+// Error is the error that a Client returns when a server responds to a request
+// with an error code.
+type Error struct {
+	Code string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("conformance error: %s", e.Code)
+}
+
+// Encode asks the server to encode a record.
+func (c *Client) Encode(record []byte) ([]byte, error) {
+	return c.roundTrip(OpEncode, record)
+}
+
+// Decode asks the server to decode an encoded record.
+func (c *Client) Decode(encoded []byte) ([]byte, error) {
+	return c.roundTrip(OpDecode, encoded)
+}
+
+func (c *Client) roundTrip(op byte, payload []byte) ([]byte, error) {
+	if err := writeFrame(c.writer, op, payload); err != nil {
+		return nil, err
+	}
+	status, err := c.reader.ReadByte()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	c.payload, err = readFrame(c.reader, c.payload)
+	if err != nil {
+		return nil, err
+	}
+	result := append([]byte{}, c.payload...)
+	switch status {
+	case StatusOK:
+		return result, nil
+	case StatusError:
+		return nil, &Error{Code: string(result)}
+	default:
+		return nil, UnknownStatus
+	}
+}
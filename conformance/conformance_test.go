@@ -0,0 +1,70 @@
+package conformance_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkClient(t *testing.T, client *conformance.Client) {
+	for _, decoded := range []string{"", "abc", "\xfe\xfd", strings.Repeat("a", 300)} {
+		encoded, err := client.Encode([]byte(decoded))
+		require.NoError(t, err)
+		roundTripped, err := client.Decode(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, decoded, string(roundTripped))
+	}
+
+	encoded, err := client.Encode([]byte("abc\xfe\xfd"))
+	require.NoError(t, err)
+	assert.Equal(t, "\x03abc\x00\x00", string(encoded))
+
+	for encoded, code := range map[string]string{
+		"\x03ab":       conformance.CodeTruncated,
+		"\xfd":         conformance.CodeInvalidRunLength,
+		"\x02\xfe\xfd": conformance.CodeEmbeddedDelimiter,
+	} {
+		_, err := client.Decode([]byte(encoded))
+		assert.Equal(t, &conformance.Error{Code: code}, err)
+	}
+}
+
+func TestServePipe(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- conformance.Serve(serverConn, serverConn)
+	}()
+	checkClient(t, conformance.NewClient(clientConn, clientConn))
+	require.NoError(t, clientConn.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestServeListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go conformance.ServeListener(l)
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	checkClient(t, conformance.NewClient(conn, conn))
+}
+
+func TestServeErrors(t *testing.T) {
+	var out bytes.Buffer
+	err := conformance.Serve(bytes.NewReader([]byte("E\x03abcD\x04\x03abc")), &out)
+	require.NoError(t, err)
+	assert.Equal(t, "O\x04\x03abcO\x03abc", out.String())
+
+	assert.Equal(t, conformance.UnknownOperation, conformance.Serve(bytes.NewReader([]byte("Q\x00")), &out))
+	assert.Equal(t, io.ErrUnexpectedEOF, conformance.Serve(bytes.NewReader([]byte("E\x05ab")), &out))
+	assert.Equal(t, io.ErrUnexpectedEOF, conformance.Serve(bytes.NewReader([]byte("E")), &out))
+}
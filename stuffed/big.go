@@ -0,0 +1,202 @@
+package stuffed
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// BigRecordBuilder builds a sorted list of stuffed records that might not fit
+// in memory.  It buffers records in memory until their content exceeds a
+// memory budget, at which point it sorts them and writes them to a temporary
+// file (a "run").  Encode then performs a k-way merge of all of the runs to
+// produce the final sorted list.
+//
+// Records are sorted bytewise, like RecordBuilder.Sort.  You must call Close
+// once you're done with a BigRecordBuilder to remove its temporary files.  A
+// BigRecordBuilder is not safe for concurrent use; if several goroutines
+// produce records, you must serialize their calls to Add.
+type BigRecordBuilder struct {
+	builder RecordBuilder
+	budget  int
+	dir     string
+	runs    []*os.File
+	opts    []Option
+	maxSize int
+}
+
+// NewBigRecordBuilder creates a BigRecordBuilder that buffers up to budget
+// bytes of record content in memory before spilling to a temporary file in
+// dir.  (If dir is empty, we use the default directory for temporary files.)
+// The options are used to encode the runs and the final output.  Because we
+// have to read the runs back in, we refuse to add any record that's larger
+// than DefaultMaxRecordSize bytes (or the MaxRecordSize of any Limits that you
+// provide).
+func NewBigRecordBuilder(budget int, dir string, opts ...Option) *BigRecordBuilder {
+	o := newOptions(opts)
+	maxSize := DefaultMaxRecordSize
+	if o.limits != nil && o.limits.MaxRecordSize > 0 {
+		maxSize = o.limits.MaxRecordSize
+	}
+	return &BigRecordBuilder{
+		builder: RecordBuilder{opts: o},
+		budget:  budget,
+		dir:     dir,
+		opts:    opts,
+		maxSize: maxSize,
+	}
+}
+
+// Add adds a record to the builder.  This might spill the records that are
+// currently in memory to a temporary file.  If the spill fails, we return an
+// error and leave the record out of the builder, so that you can retry adding
+// it.
+func (b *BigRecordBuilder) Add(record []byte) error {
+	if len(record) > b.maxSize {
+		return &ErrRecordTooLarge{Size: len(record), Limit: b.maxSize}
+	}
+	b.builder.Write(record)
+	b.builder.FinishRecord()
+	if b.builder.Len() >= b.budget {
+		if err := b.spill(); err != nil {
+			b.builder.removeLastRecord()
+			return err
+		}
+	}
+	return nil
+}
+
+// Runs returns the number of runs that we've spilled to temporary files so
+// far.
+func (b *BigRecordBuilder) Runs() int {
+	return len(b.runs)
+}
+
+// spill sorts the records that are currently in memory, and writes them to a
+// new run.  If we can't write the run, we remove its temporary file, and keep
+// the records in memory, so that the builder is unchanged (other than having
+// sorted its in-memory records).
+func (b *BigRecordBuilder) spill() error {
+	file, err := ioutil.TempFile(b.dir, "stuffed-run-*")
+	if err != nil {
+		return err
+	}
+
+	b.builder.Sort()
+	if err := b.writeBuffered(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	b.runs = append(b.runs, file)
+	b.builder.Reset()
+	return nil
+}
+
+// writeBuffered encodes the records that are currently in memory to w, in
+// their current order.  We encode one record at a time, instead of encoding
+// all of them into a buffer first, so that we never need more than the memory
+// budget plus one encoded record.
+func (b *BigRecordBuilder) writeBuffered(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	encoded := &scratch.a
+	records := b.builder.Bytes()
+	for _, index := range b.builder.recordIndices {
+		encoded.Reset()
+		b.builder.encodeRecord(index, records, encoded)
+		if _, err := out.Write(encoded.Bytes()); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Encode writes all of the records that have been added to the builder to w,
+// in sorted order.
+func (b *BigRecordBuilder) Encode(w io.Writer) error {
+	if len(b.runs) == 0 {
+		// Everything fits in memory, so there's nothing to merge.
+		b.builder.Sort()
+		return b.writeBuffered(w)
+	}
+
+	if len(b.builder.recordIndices) > 0 {
+		if err := b.spill(); err != nil {
+			return err
+		}
+	}
+	return b.merge(w)
+}
+
+// merge performs a k-way merge of all of the runs, writing the result to w.
+// The runs are already encoded, so we copy each record to the output as-is.
+func (b *BigRecordBuilder) merge(w io.Writer) error {
+	opts := &b.builder.opts
+	readers := make([]*Reader, len(b.runs))
+	done := make([]bool, len(b.runs))
+	for i, file := range b.runs {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		readers[i] = NewReader(bufio.NewReader(file), b.opts...)
+		done[i] = !readers[i].Next()
+	}
+	compare := opts.comparator()
+
+	out := bufio.NewWriter(w)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	delimiter := &scratch.a
+	opts.codec.EncodeDelimiter(delimiter)
+	for {
+		min := -1
+		for i := range readers {
+			if done[i] {
+				continue
+			}
+			if min == -1 {
+				min = i
+				continue
+			}
+			cmp, err := compare(readers[i].Encoded(), readers[min].Encoded())
+			if err != nil {
+				return err
+			}
+			if cmp < 0 {
+				min = i
+			}
+		}
+		if min == -1 {
+			break
+		}
+		out.Write(readers[min].Encoded())
+		out.Write(delimiter.Bytes())
+		done[min] = !readers[min].Next()
+	}
+
+	for _, reader := range readers {
+		if err := reader.Err(); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Close removes all of the builder's temporary files.
+func (b *BigRecordBuilder) Close() error {
+	var result error
+	for _, file := range b.runs {
+		if err := file.Close(); err != nil && result == nil {
+			result = err
+		}
+		if err := os.Remove(file.Name()); err != nil && result == nil {
+			result = err
+		}
+	}
+	b.runs = nil
+	b.builder.Reset()
+	return result
+}
@@ -0,0 +1,93 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkBigRecordBuilder(t *testing.T, inputList []string, budget int, expectedRuns int, opts ...stuffed.Option) {
+	dir, err := ioutil.TempDir("", "stuffed-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	builder := stuffed.NewBigRecordBuilder(budget, dir, opts...)
+	for _, input := range inputList {
+		require.NoError(t, builder.Add([]byte(input)))
+	}
+	var encoded bytes.Buffer
+	require.NoError(t, builder.Encode(&encoded))
+	assert.Equal(t, expectedRuns, builder.Runs())
+
+	sorted := append([]string{}, inputList...)
+	sort.Strings(sorted)
+	expected := stuffed.NewRecordBuilder(opts...)
+	for _, input := range sorted {
+		expected.WriteString(input)
+		expected.FinishRecord()
+	}
+	var expectedEncoded bytes.Buffer
+	expected.Encode(&expectedEncoded)
+	assert.Equal(t, expectedEncoded.Bytes(), encoded.Bytes())
+
+	require.NoError(t, builder.Close())
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestBigRecordBuilder(t *testing.T) {
+	checkBigRecordBuilder(t, nil, 100, 0)
+	checkBigRecordBuilder(t, shortTestCaseInputs(), 1<<20, 0)
+	checkBigRecordBuilder(t, shortTestCaseInputs(), 100, 4)
+
+	var inputList []string
+	for i := 0; i < 1000; i++ {
+		inputList = append(inputList, fmt.Sprintf("%d\xfe\xfd%d", (i*7919)%1000, i))
+	}
+	checkBigRecordBuilder(t, inputList, 1000, 8)
+	checkBigRecordBuilder(t, inputList, 1000, 8, stuffed.WithChecksum())
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	checkBigRecordBuilder(t, inputList, 1000, 8, stuffed.WithCodec(codec))
+}
+
+func TestBigRecordBuilderAtLimit(t *testing.T) {
+	limits := stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 300})
+	inputList := []string{strings.Repeat("b", 300), strings.Repeat("a", 300)}
+	checkBigRecordBuilder(t, inputList, 100, 2, limits)
+	checkBigRecordBuilder(t, inputList, 100, 2, limits, stuffed.WithChecksum())
+}
+
+func TestBigRecordBuilderFailedSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stuffed-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// We can't create temporary files in a directory that doesn't exist.
+	builder := stuffed.NewBigRecordBuilder(5, dir+"/missing")
+	defer builder.Close()
+	require.NoError(t, builder.Add([]byte("ab")))
+	assert.Error(t, builder.Add([]byte("cde")))
+	assert.Equal(t, 0, builder.Runs())
+
+	// The record that we couldn't spill isn't in the output.
+	var encoded bytes.Buffer
+	require.NoError(t, builder.Encode(&encoded))
+	assert.Equal(t, encodeList([]string{"ab"}), encoded.Bytes())
+}
+
+func TestBigRecordBuilderTooLarge(t *testing.T) {
+	builder := stuffed.NewBigRecordBuilder(100, "", stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 3}))
+	defer builder.Close()
+	assert.NoError(t, builder.Add([]byte("abc")))
+	assert.IsType(t, &stuffed.ErrRecordTooLarge{}, builder.Add([]byte("abcd")))
+}
@@ -0,0 +1,80 @@
+package stuffed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var (
+	// ErrChecksumMismatch is the error that is returned when the CRC32
+	// checksum stored in a record doesn't match the record's content.
+	ErrChecksumMismatch = errors.New("Record checksum mismatch")
+)
+
+// checksumLength is the size of the CRC32 checksum that EncodeWithChecksum
+// appends to each record.
+const checksumLength = 4
+
+// EncodeWithChecksum writes a binary record to an output buffer using the
+// stuffed records encoding, appending a CRC32 (IEEE) checksum of the record's
+// content.  The checksum is stored little-endian at the end of the record's
+// content, before stuffing, so the result is still an ordinary stuffed record,
+// and can be delimited and scanned like any other.  Use DecodeWithChecksum to
+// verify and remove the checksum.
+func EncodeWithChecksum(record []byte, dest *bytes.Buffer) {
+	scratch := getScratch()
+	defer putScratch(scratch)
+	scratch.raw = appendChecksum(append(scratch.raw, record...))
+	Encode(scratch.raw, dest)
+}
+
+// appendChecksum appends a checksum of a record's content to the record.
+func appendChecksum(record []byte) []byte {
+	var checksum [checksumLength]byte
+	binary.LittleEndian.PutUint32(checksum[:], crc32.ChecksumIEEE(record))
+	return append(record, checksum[:]...)
+}
+
+// DecodeWithChecksum reads a binary record that was written by
+// EncodeWithChecksum, verifying its checksum.  The record's content, without
+// the checksum, is written to the output buffer.  If the checksum doesn't
+// match, we return ErrChecksumMismatch and leave the output buffer unchanged.
+func DecodeWithChecksum(encoded []byte, record *bytes.Buffer) error {
+	start := record.Len()
+	if err := Decode(encoded, record); err != nil {
+		record.Truncate(start)
+		return err
+	}
+	if err := verifyChecksum(record.Bytes()[start:]); err != nil {
+		record.Truncate(start)
+		return err
+	}
+	record.Truncate(record.Len() - checksumLength)
+	return nil
+}
+
+// verifyChecksum checks that the last bytes of a decoded record are a valid
+// checksum of the rest of its content.
+func verifyChecksum(decoded []byte) error {
+	if len(decoded) < checksumLength {
+		return ErrChecksumMismatch
+	}
+	content := decoded[:len(decoded)-checksumLength]
+	expected := binary.LittleEndian.Uint32(decoded[len(content):])
+	if crc32.ChecksumIEEE(content) != expected {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// DecodeWithChecksum reads the current stuffed record, which must have been
+// written by EncodeWithChecksum, verifies its checksum, and decodes it into an
+// output Buffer.  We decode the record using the Scanner's options, so this
+// works with a Scanner that uses a Codec, too.
+func (s *Scanner) DecodeWithChecksum(decoded *bytes.Buffer) error {
+	opts := s.opts
+	opts.checksum = true
+	return opts.decode(s.record, decoded)
+}
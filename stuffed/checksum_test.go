@@ -0,0 +1,68 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksums(t *testing.T) {
+	var encoded bytes.Buffer
+	for _, input := range shortTestCaseInputs() {
+		stuffed.EncodeWithChecksum([]byte(input), &encoded)
+		stuffed.EncodeDelimiter(&encoded)
+	}
+
+	var actual []string
+	var s stuffed.Scanner
+	s.Reset(encoded.Bytes())
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.DecodeWithChecksum(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, shortTestCaseInputs(), actual)
+
+	encoded.Reset()
+	stuffed.EncodeWithChecksum([]byte("abc"), &encoded)
+	assert.Equal(t, "\x07abc\xc2\x41\x24\x35", encoded.String())
+
+	// Flip a bit in the content.
+	corrupted := append([]byte{}, encoded.Bytes()...)
+	corrupted[2] ^= 0x01
+	decoded := bytes.NewBufferString("prefix")
+	assert.Equal(t, stuffed.ErrChecksumMismatch, stuffed.DecodeWithChecksum(corrupted, decoded))
+	assert.Equal(t, "prefix", decoded.String())
+
+	// Records without checksums are too short, or fail to verify.
+	assert.Equal(t, stuffed.ErrChecksumMismatch, stuffed.DecodeWithChecksum([]byte("\x03abc"), decoded))
+	assert.Equal(t, stuffed.ErrChecksumMismatch, stuffed.DecodeWithChecksum([]byte("\x04abcd"), decoded))
+	assert.Equal(t, io.EOF, stuffed.DecodeWithChecksum([]byte("\x07abc"), decoded))
+	assert.Equal(t, "prefix", decoded.String())
+}
+
+func TestScannerChecksumsWithCodec(t *testing.T) {
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	inputs := []string{"abc", "a\x00b", "\xfe\xfd"}
+	rb := stuffed.NewRecordBuilder(stuffed.WithCodec(codec), stuffed.WithChecksum())
+	for _, input := range inputs {
+		rb.WriteString(input)
+		rb.FinishRecord()
+	}
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+
+	var actual []string
+	s := codec.Scanner(encoded.Bytes())
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.DecodeWithChecksum(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputs, actual)
+}
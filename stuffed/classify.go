@@ -0,0 +1,67 @@
+package stuffed
+
+import (
+	"sort"
+)
+
+// ClassifyByPrefix finds which of a sorted list of prefixes the decoded content
+// of a stuffed record begins with, returning the index of that prefix, or -1 if
+// the record doesn't begin with any of them.  If more than one prefix matches
+// (because some prefixes are themselves prefixes of others), we return the
+// longest one.  We make a single pass over the record's runs, regardless of how
+// many prefixes there are, and stop as soon as no more prefixes can match.
+// (You provide the _encoded_ stuffed record, and we perform the check without
+// decoding the content into a buffer.)
+func ClassifyByPrefix(encoded []byte, sortedPrefixes [][]byte) (int, error) {
+	var c *Codec
+	return c.ClassifyByPrefix(encoded, sortedPrefixes)
+}
+
+// ClassifyByPrefix finds which of a sorted list of prefixes the decoded content
+// of a record that was encoded with this Codec begins with, just like the
+// package-level ClassifyByPrefix.
+func (c *Codec) ClassifyByPrefix(encoded []byte, sortedPrefixes [][]byte) (int, error) {
+	// All of the prefixes in [lo, hi) match the content that we've seen so
+	// far.  Because the prefixes are sorted, any of them that are exactly as
+	// long as the content we've seen come first.
+	lo, hi := 0, len(sortedPrefixes)
+	consumed := 0
+	result := -1
+	skipComplete := func() {
+		for lo < hi && len(sortedPrefixes[lo]) == consumed {
+			result = lo
+			lo++
+		}
+	}
+
+	r := c.chunks(encoded)
+	for {
+		skipComplete()
+		if lo == hi {
+			return result, nil
+		}
+		chunk, ok, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return result, nil
+		}
+		for _, b := range chunk {
+			skipComplete()
+			if lo == hi {
+				return result, nil
+			}
+			// Narrow the range to the prefixes whose next byte is b.
+			candidates := sortedPrefixes[lo:hi]
+			first := sort.Search(len(candidates), func(i int) bool {
+				return candidates[i][consumed] >= b
+			})
+			last := sort.Search(len(candidates), func(i int) bool {
+				return candidates[i][consumed] > b
+			})
+			lo, hi = lo+first, lo+last
+			consumed++
+		}
+	}
+}
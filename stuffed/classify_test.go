@@ -0,0 +1,57 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// classifyByPrefix is a simple implementation of ClassifyByPrefix that checks
+// each prefix in turn.
+func classifyByPrefix(decoded string, sortedPrefixes []string) int {
+	result := -1
+	for i, prefix := range sortedPrefixes {
+		if strings.HasPrefix(decoded, prefix) && (result == -1 || len(prefix) > len(sortedPrefixes[result])) {
+			result = i
+		}
+	}
+	return result
+}
+
+func TestClassifyByPrefix(t *testing.T) {
+	prefixSets := [][]string{
+		{},
+		{""},
+		{"", "a", "abc", "abd", "b"},
+		{"abc\xfe", "abc\xfe\xfd", "abc\xfe\xfda", "abc\xfe\xfdabc"},
+		{"abcdefghijklmnopqrstuvwxyz012345abcdefghijklmnopqrstuvwxyz012345", "x"},
+		{"\xfe", "\xfe\xfd"},
+	}
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	for _, prefixes := range prefixSets {
+		sort.Strings(prefixes)
+		sortedPrefixes := make([][]byte, len(prefixes))
+		for i, prefix := range prefixes {
+			sortedPrefixes[i] = []byte(prefix)
+		}
+		for _, input := range shortTestCaseInputs() {
+			expected := classifyByPrefix(input, prefixes)
+			for _, c := range []*stuffed.Codec{stuffed.DefaultCodec, codec} {
+				var encoded bytes.Buffer
+				c.Encode([]byte(input), &encoded)
+				actual, err := c.ClassifyByPrefix(encoded.Bytes(), sortedPrefixes)
+				require.NoError(t, err)
+				assert.Equal(t, expected, actual, "%q in %q", input, prefixes)
+			}
+		}
+	}
+
+	_, err = stuffed.ClassifyByPrefix([]byte("\x03ab"), [][]byte{[]byte("abc")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,278 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// InvalidDelimiter is the error that is returned when you try to create a
+	// Codec with a delimiter that it can't support.
+	InvalidDelimiter = errors.New("Invalid delimiter")
+)
+
+// Codec implements the stuffed records encoding with a configurable delimiter.
+// The delimiter can be one or two bytes long; a two-byte delimiter must consist
+// of two different bytes, so that it can't overlap with itself.
+//
+// The encoding has the same structure as the default one: a one-byte header
+// for the first run, and two-byte headers for the remaining runs, with run
+// lengths written in base 253.  The only difference is which byte represents
+// each digit of a run header.  We assign the digits, in order, to the byte
+// values that don't appear in the delimiter, so run headers can never contain
+// part of the delimiter.  For the default `0xfe 0xfd` delimiter, each digit is
+// represented by the byte with the same value, and so a Codec with the default
+// delimiter produces exactly the same output as the package-level functions.
+//
+// Note that a Codec with a `0x00` delimiter is _not_ compatible with classic
+// COBS, which uses a different run header format.  Use the sibling cobs package
+// for that.
+//
+// A Codec never changes once you've created it, so you can share one between
+// any number of goroutines.
+type Codec struct {
+	delimiter []byte
+	// digits maps each run header digit to the byte that represents it, and
+	// values maps each byte back to the digit that it represents (or -1 if it
+	// doesn't represent one).
+	digits [radix]byte
+	values [256]int16
+}
+
+// DefaultCodec is a Codec that uses the default `0xfe 0xfd` delimiter.
+var DefaultCodec = &Codec{delimiter: delimiterBytes}
+
+// NewCodec creates a Codec that uses a particular delimiter.  If delim is
+// empty, we use the default `0xfe 0xfd` delimiter.
+func NewCodec(delim []byte) (*Codec, error) {
+	if len(delim) == 0 || bytes.Equal(delim, delimiterBytes) {
+		return DefaultCodec, nil
+	}
+	if len(delim) > 2 || (len(delim) == 2 && delim[0] == delim[1]) {
+		return nil, InvalidDelimiter
+	}
+
+	c := &Codec{delimiter: append([]byte{}, delim...)}
+	for i := range c.values {
+		c.values[i] = -1
+	}
+	digit := 0
+	for b := 0; b < 256 && digit < radix; b++ {
+		if bytes.IndexByte(delim, byte(b)) != -1 {
+			continue
+		}
+		c.digits[digit] = byte(b)
+		c.values[b] = int16(digit)
+		digit++
+	}
+	return c, nil
+}
+
+// isDefault returns whether a Codec uses the default delimiter, in which case
+// we can use the package-level functions directly.  A nil Codec uses the
+// default delimiter.
+func (c *Codec) isDefault() bool {
+	return c == nil || c == DefaultCodec
+}
+
+// delim returns a Codec's delimiter, without copying it.
+func (c *Codec) delim() []byte {
+	if c == nil {
+		return delimiterBytes
+	}
+	return c.delimiter
+}
+
+// digit returns the value of the run header digit that a byte represents, or
+// false if it doesn't represent one.
+func (c *Codec) digit(b byte) (int, bool) {
+	if c.isDefault() {
+		return int(b), true
+	}
+	value := c.values[b]
+	return int(value), value >= 0
+}
+
+// chunks returns a chunkReader for a record encoded with this Codec.
+func (c *Codec) chunks(encoded []byte) chunkReader {
+	r := newChunkReader(encoded)
+	if !c.isDefault() {
+		r.codec = c
+	}
+	return r
+}
+
+// Delimiter returns a copy of the delimiter that a Codec uses.
+func (c *Codec) Delimiter() []byte {
+	return append([]byte{}, c.delim()...)
+}
+
+// FindDelimiter returns the index of the first occurrence of the Codec's
+// delimiter in buf, or -1 if it doesn't occur.
+func (c *Codec) FindDelimiter(buf []byte) int {
+	return bytes.Index(buf, c.delim())
+}
+
+// findDelimiter looks for the delimiter within the first maxRun bytes of
+// record, just like the package-level findDelimiter.
+func (c *Codec) findDelimiter(record []byte, maxRun int) int {
+	if len(record) < maxRun {
+		maxRun = len(record)
+	} else {
+		record = record[:maxRun]
+	}
+	result := bytes.Index(record, c.delim())
+	if result == -1 {
+		return maxRun
+	}
+	return result
+}
+
+// EncodeDelimiter writes the Codec's delimiter to an output buffer.
+func (c *Codec) EncodeDelimiter(buf *bytes.Buffer) {
+	buf.Write(c.delim())
+}
+
+// MaxEncodedLen returns the largest number of bytes that Encode can produce for
+// an n-byte record, just like the package-level MaxEncodedLen.  A two-byte
+// delimiter is replaced by a run header of the same size, so it never makes the
+// encoding larger.  A one-byte delimiter is replaced by a two-byte run header,
+// though, so in the worst case (a record consisting entirely of delimiters),
+// each byte of the record adds an extra byte to the encoding.
+func (c *Codec) MaxEncodedLen(n int) int {
+	if len(c.delim()) == 1 {
+		return MaxEncodedLen(n) + n
+	}
+	return MaxEncodedLen(n)
+}
+
+// Encode writes a binary record into an output buffer, just like the
+// package-level Encode, but using the Codec's delimiter.
+func (c *Codec) Encode(record []byte, buf *bytes.Buffer) {
+	if c.isDefault() {
+		Encode(record, buf)
+		return
+	}
+
+	buf.Grow(c.MaxEncodedLen(len(record)))
+	delimiterLength := len(c.delimiter)
+
+	// For the first run, we encode a maximum of 252 characters, so that we can
+	// encode the length in a single digit.
+	runSize := c.findDelimiter(record, maxInitialRun)
+	buf.WriteByte(c.digits[runSize])
+	buf.Write(record[:runSize])
+	record = record[runSize:]
+	if runSize < maxInitialRun {
+		// We reached the end (with a virtual terminating delimiter).
+		if len(record) == 0 {
+			return
+		}
+
+		// record should start with delimiter, so skip over it.
+		record = record[delimiterLength:]
+	}
+
+	// For any remaining runs, we encode a maximum of 64008 characters, encoding
+	// the length in two digits.
+	for {
+		runSize := c.findDelimiter(record, maxRemainingRun)
+		buf.WriteByte(c.digits[runSize%radix])
+		buf.WriteByte(c.digits[runSize/radix])
+		buf.Write(record[:runSize])
+		record = record[runSize:]
+		if runSize < maxRemainingRun {
+			// We reached the end (with a virtual terminating delimiter).
+			if len(record) == 0 {
+				return
+			}
+
+			// record should start with delimiter, so skip over it.
+			record = record[delimiterLength:]
+		}
+	}
+}
+
+// Decode reads a binary record that was encoded with this Codec, and decodes it
+// into an output buffer.  You must ensure that the encoded record does not
+// contain any occurrences of the delimiter.
+func (c *Codec) Decode(encoded []byte, record *bytes.Buffer) error {
+	if c.isDefault() {
+		return Decode(encoded, record)
+	}
+	r := c.chunks(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil || !ok {
+			return err
+		}
+		record.Write(chunk)
+	}
+}
+
+// DecodeWithLimits reads a binary record that was encoded with this Codec, just
+// like Decode, but returns an error as soon as the record exceeds any of the
+// given limits, just like the package-level DecodeWithLimits.
+func (c *Codec) DecodeWithLimits(encoded []byte, record *bytes.Buffer, limits Limits) error {
+	size := 0
+	r := c.chunks(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			limits.warn(r.runs, size)
+			return nil
+		}
+		if limits.MaxRuns > 0 && r.runs > limits.MaxRuns {
+			return TooManyRuns
+		}
+		size += len(chunk)
+		if err := limits.checkSize(size); err != nil {
+			return err
+		}
+		record.Write(chunk)
+	}
+}
+
+// CompareEncodedPrefix checks whether the decoded content of a record that was
+// encoded with this Codec begins with a prefix, just like the package-level
+// CompareEncodedPrefix.
+func (c *Codec) CompareEncodedPrefix(encoded, prefix []byte) (int, error) {
+	if c.isDefault() {
+		return CompareEncodedPrefix(encoded, prefix)
+	}
+	r := c.chunks(encoded)
+	for len(prefix) > 0 {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			// The record is a proper prefix of the prefix.
+			return -1, nil
+		}
+		cmp, consumed := checkPrefix(chunk, prefix)
+		if cmp != 0 {
+			return cmp, nil
+		}
+		prefix = prefix[consumed:]
+	}
+	return 0, nil
+}
+
+// Scanner returns a Scanner that iterates through a buffer containing records
+// that were encoded with this Codec.  The Scanner keeps using this Codec if you
+// Reset it.
+func (c *Codec) Scanner(encodedList []byte) *Scanner {
+	return NewScanner(encodedList, WithCodec(c))
+}
+
+// FindRecordsWithPrefix takes a buffer containing a list of records that were
+// encoded with this Codec and are sorted by their decoded content, and returns
+// the subset of the buffer containing records whose decoded content starts with
+// a particular prefix, just like the package-level FindRecordsWithPrefix.
+func (c *Codec) FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
+	return findRecordsWithPrefix(encodedList, prefix, c.delim(), c.CompareEncodedPrefix, nil)
+}
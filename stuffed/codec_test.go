@@ -0,0 +1,124 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var codecDelimiters = []string{"\xfe\xfd", "\x00", "\n", "\x00\x01", "\xff\xfe", "ab"}
+
+// codecInputs returns test inputs that contain delim in interesting places.
+func codecInputs(delim string) []string {
+	return []string{
+		"",
+		"xyz",
+		delim,
+		delim + delim,
+		"abc" + delim,
+		delim + "abc",
+		"abc" + delim + "abc",
+		"\xfe\xfd\x00\n",
+		strings.Repeat("c", 300) + delim + "d",
+		strings.Repeat("e", 64008) + delim,
+		strings.Repeat("f", 70000),
+	}
+}
+
+func checkCodecRoundTrip(t require.TestingT, codec *stuffed.Codec, inputList []string) {
+	var encoded bytes.Buffer
+	for _, input := range inputList {
+		start := encoded.Len()
+		codec.Encode([]byte(input), &encoded)
+		assert.Equal(t, -1, codec.FindDelimiter(encoded.Bytes()[start:]))
+		codec.EncodeDelimiter(&encoded)
+	}
+
+	actual := []string{}
+	s := codec.Scanner(encoded.Bytes())
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputList, actual)
+}
+
+func TestCodecs(t *testing.T) {
+	codec, err := stuffed.NewCodec(nil)
+	require.NoError(t, err)
+	assert.Equal(t, stuffed.DefaultCodec, codec)
+	assert.Equal(t, []byte("\xfe\xfd"), codec.Delimiter())
+	for _, tc := range shortTestCases {
+		var encoded bytes.Buffer
+		codec.Encode([]byte(tc.decoded), &encoded)
+		assert.Equal(t, tc.encoded, encoded.String())
+	}
+
+	for _, delim := range []string{"\x00\x00", "abc"} {
+		_, err := stuffed.NewCodec([]byte(delim))
+		assert.Equal(t, stuffed.InvalidDelimiter, err)
+	}
+
+	for _, delim := range codecDelimiters {
+		codec, err := stuffed.NewCodec([]byte(delim))
+		require.NoError(t, err)
+		assert.Equal(t, []byte(delim), codec.Delimiter())
+		checkCodecRoundTrip(t, codec, codecInputs(delim))
+	}
+}
+
+func TestCodecEncoding(t *testing.T) {
+	// With a 0x00 delimiter, each digit d is represented by the byte d+1.
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	var encoded bytes.Buffer
+	codec.Encode([]byte("abc\x00de"), &encoded)
+	assert.Equal(t, "\x04abc\x03\x01de", encoded.String())
+
+	var decoded bytes.Buffer
+	require.NoError(t, codec.Decode(encoded.Bytes(), &decoded))
+	assert.Equal(t, "abc\x00de", decoded.String())
+
+	// 0x00 never represents a digit.
+	assert.Equal(t, stuffed.InvalidRunLength, codec.Decode([]byte("\x01\x00\x01"), &decoded))
+}
+
+func TestCodecFindRecordsWithPrefix(t *testing.T) {
+	for _, delim := range codecDelimiters {
+		codec, err := stuffed.NewCodec([]byte(delim))
+		require.NoError(t, err)
+		inputList := codecInputs(delim)
+		sort.Strings(inputList)
+		var encoded bytes.Buffer
+		for _, input := range inputList {
+			codec.EncodeDelimiter(&encoded)
+			codec.Encode([]byte(input), &encoded)
+		}
+
+		for _, prefix := range []string{"", "abc", "abc" + delim, delim, "c", "x", "zzz"} {
+			expected := []string{}
+			for _, input := range inputList {
+				if strings.HasPrefix(input, prefix) {
+					expected = append(expected, input)
+				}
+			}
+
+			matching, err := codec.FindRecordsWithPrefix(encoded.Bytes(), []byte(prefix))
+			require.NoError(t, err)
+			actual := []string{}
+			s := codec.Scanner(matching)
+			for s.Next() {
+				var decoded bytes.Buffer
+				require.NoError(t, s.Decode(&decoded))
+				actual = append(actual, decoded.String())
+			}
+			assert.Equal(t, expected, actual, "delimiter %q, prefix %q", delim, prefix)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package stuffed
+
+import (
+	"bytes"
+)
+
+// Collation defines an ordering of the decoded content of records.  If you sort
+// a list of records using a collation, you must use the same collation to
+// search or merge it.
+type Collation interface {
+	// Compare returns -1, 0, or 1 depending on whether a sorts before, the
+	// same as, or after b.
+	Compare(a, b []byte) int
+
+	// ComparePrefix returns 0 if decoded begins with prefix.  If it does not,
+	// returns -1 or 1 depending on whether decoded sorts before or after all of
+	// the strings that begin with prefix.  The strings that begin with a prefix
+	// must be contiguous in the collation's ordering.
+	ComparePrefix(decoded, prefix []byte) int
+}
+
+// Bytewise is the default Collation, which orders records lexicographically by
+// their bytes.
+var Bytewise Collation = bytewise{}
+
+type bytewise struct{}
+
+func (bytewise) Compare(a, b []byte) int {
+	return bytes.Compare(a, b)
+}
+
+func (bytewise) ComparePrefix(decoded, prefix []byte) int {
+	if len(decoded) > len(prefix) {
+		decoded = decoded[:len(prefix)]
+	}
+	return bytes.Compare(decoded, prefix)
+}
+
+// CaseInsensitive is a Collation that orders records lexicographically by
+// their bytes, ignoring the case of ASCII letters.
+var CaseInsensitive Collation = caseInsensitive{}
+
+type caseInsensitive struct{}
+
+func foldASCII(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
+
+func (caseInsensitive) Compare(a, b []byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := foldASCII(a[i]), foldASCII(b[i])
+		if ca < cb {
+			return -1
+		}
+		if ca > cb {
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (c caseInsensitive) ComparePrefix(decoded, prefix []byte) int {
+	if len(decoded) > len(prefix) {
+		decoded = decoded[:len(prefix)]
+	}
+	return c.Compare(decoded, prefix)
+}
+
+// encodedComparator returns a function that compares two encoded records
+// using a collation.  For the Bytewise collation, we compare the records
+// without decoding them; for any other collation, we have to decode them
+// first.
+func encodedComparator(collation Collation) func(a, b []byte) (int, error) {
+	if collation == nil || collation == Bytewise {
+		return CompareEncoded
+	}
+	var decodedA, decodedB bytes.Buffer
+	return func(a, b []byte) (int, error) {
+		decodedA.Reset()
+		if err := Decode(a, &decodedA); err != nil {
+			return 0, err
+		}
+		decodedB.Reset()
+		if err := Decode(b, &decodedB); err != nil {
+			return 0, err
+		}
+		return collation.Compare(decodedA.Bytes(), decodedB.Bytes()), nil
+	}
+}
+
+// encodedPrefixComparator returns a function that checks whether an encoded
+// record begins with a prefix using a collation, like CompareEncodedPrefix.
+func encodedPrefixComparator(collation Collation) func(encoded, prefix []byte) (int, error) {
+	if collation == nil || collation == Bytewise {
+		return CompareEncodedPrefix
+	}
+	var decoded bytes.Buffer
+	return func(encoded, prefix []byte) (int, error) {
+		decoded.Reset()
+		if err := Decode(encoded, &decoded); err != nil {
+			return 0, err
+		}
+		return collation.ComparePrefix(decoded.Bytes(), prefix), nil
+	}
+}
+
+// FindRecordsWithPrefixCollated is like FindRecordsWithPrefix, but for a list
+// of records that is sorted using a collation.  The collation also determines
+// whether each record starts with the prefix.  Unless the collation is
+// Bytewise, we have to decode each record that we look at.
+func FindRecordsWithPrefixCollated(encodedList, prefix []byte, collation Collation) ([]byte, error) {
+	return findRecordsWithPrefix(encodedList, prefix, delimiterBytes, encodedPrefixComparator(collation), nil)
+}
+
+// MergeSorted performs a k-way merge of several lists of stuffed records, each
+// of which must be sorted using collation, returning the encoded content of
+// each record in sorted order.  (Records that compare equal are returned in
+// list order.)  If collation is nil, we use Bytewise.
+func MergeSorted(lists [][]byte, collation Collation) ([][]byte, error) {
+	return mergeSortedLists(lists, encodedComparator(collation))
+}
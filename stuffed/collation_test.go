@@ -0,0 +1,79 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeCollated(inputList []string, collation stuffed.Collation) []byte {
+	var rb stuffed.RecordBuilder
+	for _, input := range inputList {
+		rb.WriteString(input)
+		rb.FinishRecord()
+	}
+	rb.SortWithCollation(collation)
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+	return encoded.Bytes()
+}
+
+func TestCaseInsensitiveCollation(t *testing.T) {
+	inputList := []string{"abd", "Abc", "B", "aB", "a", "ABE\xfe\xfd", "c", "\xfe\xfd"}
+	encoded := encodeCollated(inputList, stuffed.CaseInsensitive)
+	decoded, err := parseStrings(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "aB", "Abc", "abd", "ABE\xfe\xfd", "B", "c", "\xfe\xfd"}, decoded)
+
+	for _, tc := range []struct {
+		prefix   string
+		expected []string
+	}{
+		{"", decoded},
+		{"AB", []string{"aB", "Abc", "abd", "ABE\xfe\xfd"}},
+		{"abe\xfe", []string{"ABE\xfe\xfd"}},
+		{"b", []string{"B"}},
+		{"bb", []string{}},
+	} {
+		matching, err := stuffed.FindRecordsWithPrefixCollated(encoded, []byte(tc.prefix), stuffed.CaseInsensitive)
+		require.NoError(t, err)
+		actual, err := parseStrings(matching)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, actual, "prefix %q", tc.prefix)
+	}
+
+	lists := [][]byte{
+		encodeCollated([]string{"Apple", "cherry"}, stuffed.CaseInsensitive),
+		encodeCollated([]string{"banana", "apricot", "Date"}, stuffed.CaseInsensitive),
+	}
+	merged, err := stuffed.MergeSorted(lists, stuffed.CaseInsensitive)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Apple", "apricot", "banana", "cherry", "Date"}, decodeAll(t, merged))
+
+	// A merge has to use the same collation as the lists were sorted with.
+	merged, err = stuffed.MergeSorted(lists, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Apple", "apricot", "banana", "Date", "cherry"}, decodeAll(t, merged))
+}
+
+func TestBytewiseCollation(t *testing.T) {
+	for _, tc := range prefixTestCases {
+		expected := append([]string{}, tc.expected...)
+		encoded := encodeCollated(shortTestCaseInputs(), stuffed.Bytewise)
+		matching, err := stuffed.FindRecordsWithPrefixCollated(encoded, []byte(tc.prefix), stuffed.Bytewise)
+		require.NoError(t, err)
+		actual, err := parseStrings(matching)
+		require.NoError(t, err)
+		sort.Strings(expected)
+		assert.Equal(t, expected, actual)
+	}
+
+	lists := [][]byte{encodeList([]string{"B", "a"}), encodeList([]string{"C", "b"})}
+	merged, err := stuffed.MergeSorted(lists, stuffed.Bytewise)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"B", "C", "a", "b"}, decodeAll(t, merged))
+}
@@ -0,0 +1,129 @@
+package stuffed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// ToLengthPrefixed converts a stream of delimited stuffed records into a
+// stream of records that are each prefixed with their length, encoded as an
+// unsigned varint (as used by encoding/binary and by protobuf's delimited
+// streams).  Record boundaries are preserved exactly.
+func ToLengthPrefixed(r io.Reader, w io.Writer) error {
+	reader := NewReader(r)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var length [binary.MaxVarintLen64]byte
+	for reader.Next() {
+		decoded.Reset()
+		if err := reader.Decode(decoded); err != nil {
+			return err
+		}
+		n := binary.PutUvarint(length[:], uint64(decoded.Len()))
+		if _, err := w.Write(length[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(decoded.Bytes()); err != nil {
+			return err
+		}
+	}
+	return reader.Err()
+}
+
+// FromLengthPrefixed converts a stream of varint-length-prefixed records (as
+// produced by ToLengthPrefixed) into a stream of delimited stuffed records.
+// We refuse to read any record larger than DefaultMaxRecordSize.
+func FromLengthPrefixed(r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := scratch.raw
+	encoded := &scratch.a
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if length > DefaultMaxRecordSize {
+			return &ErrRecordTooLarge{Size: int(length), Limit: DefaultMaxRecordSize}
+		}
+
+		if cap(decoded) < int(length) {
+			decoded = make([]byte, length)
+			scratch.raw = decoded
+		}
+		decoded = decoded[:length]
+		if _, err := io.ReadFull(reader, decoded); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		encoded.Reset()
+		Encode(decoded, encoded)
+		EncodeDelimiter(encoded)
+		if _, err := w.Write(encoded.Bytes()); err != nil {
+			return err
+		}
+	}
+}
+
+// IngestLines reads newline-delimited text from r, and adds each line to a
+// RecordBuilder as a separate record.  We remove the trailing newline (and the
+// carriage return before it, if any) from each line.  The last line doesn't
+// need to end with a newline; if it doesn't, we leave it untouched, even if it
+// ends with a carriage return.  If any line is longer than maxLine bytes (or
+// DefaultMaxRecordSize, if maxLine isn't positive), we return an
+// ErrRecordTooLarge error.  If we return an error, the builder will contain the
+// lines that we read before the error, but not the partial line that we were
+// in the middle of reading.  You can't call this while you're in the middle of
+// building a record.
+func IngestLines(r io.Reader, builder *RecordBuilder, maxLine int) error {
+	if builder.start != builder.Len() {
+		return UnfinishedRecord
+	}
+	if maxLine <= 0 {
+		maxLine = DefaultMaxRecordSize
+	}
+	reader := bufio.NewReader(r)
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		builder.Write(chunk)
+		if err == bufio.ErrBufferFull {
+			// Leave room for a trailing CRLF, which won't count against the
+			// limit.
+			if size := builder.Len() - builder.start; size > maxLine+2 {
+				builder.Truncate(builder.start)
+				return &ErrRecordTooLarge{Size: size, Limit: maxLine}
+			}
+			continue
+		} else if err != nil && err != io.EOF {
+			builder.Truncate(builder.start)
+			return err
+		}
+
+		line := builder.Bytes()[builder.start:]
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if bytes.HasSuffix(line, []byte{'\n'}) {
+			line = line[:len(line)-1]
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+		}
+		if len(line) > maxLine {
+			builder.Truncate(builder.start)
+			return &ErrRecordTooLarge{Size: len(line), Limit: maxLine}
+		}
+		builder.Truncate(builder.start + len(line))
+		builder.FinishRecord()
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLengthPrefixedConversion(t *testing.T) {
+	var lengthPrefixed bytes.Buffer
+	encoded := encodeList([]string{"abc", "", "\xfe\xfd"})
+	require.NoError(t, stuffed.ToLengthPrefixed(bytes.NewReader(encoded), &lengthPrefixed))
+	assert.Equal(t, "\x03abc\x00\x02\xfe\xfd", lengthPrefixed.String())
+
+	var roundTripped bytes.Buffer
+	require.NoError(t, stuffed.FromLengthPrefixed(&lengthPrefixed, &roundTripped))
+	assert.Equal(t, encoded, roundTripped.Bytes())
+
+	inputList := shortTestCaseInputs()
+	encoded = encodeList(inputList)
+	lengthPrefixed.Reset()
+	roundTripped.Reset()
+	require.NoError(t, stuffed.ToLengthPrefixed(bytes.NewReader(encoded), &lengthPrefixed))
+	require.NoError(t, stuffed.FromLengthPrefixed(&lengthPrefixed, &roundTripped))
+	assert.Equal(t, encoded, roundTripped.Bytes())
+
+	err := stuffed.FromLengthPrefixed(bytes.NewReader([]byte("\x03ab")), &roundTripped)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	err = stuffed.ToLengthPrefixed(bytes.NewReader([]byte("\x05ab")), &lengthPrefixed)
+	assert.Equal(t, io.EOF, err)
+}
+
+func ingestLines(t *testing.T, input string, maxLine int) ([]string, error) {
+	var builder stuffed.RecordBuilder
+	err := stuffed.IngestLines(iotest.OneByteReader(strings.NewReader(input)), &builder, maxLine)
+	var encoded bytes.Buffer
+	builder.Encode(&encoded)
+	actual, parseErr := parseStrings(encoded.Bytes())
+	require.NoError(t, parseErr)
+	return actual, err
+}
+
+func TestIngestLines(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"\n", []string{""}},
+		{"abc", []string{"abc"}},
+		{"abc\n", []string{"abc"}},
+		{"abc\r\ndef\n\nghi", []string{"abc", "def", "", "ghi"}},
+		{"abc\rdef\r\n\r", []string{"abc\rdef", "\r"}},
+		{"abc\r", []string{"abc\r"}},
+		{"abc\r\r\n", []string{"abc\r"}},
+		{"a\xfe\xfdb\n", []string{"a\xfe\xfdb"}},
+	}
+	for _, tc := range testCases {
+		actual, err := ingestLines(t, tc.input, 0)
+		require.NoError(t, err, "%q", tc.input)
+		assert.Equal(t, tc.expected, actual, "%q", tc.input)
+	}
+
+	long := strings.Repeat("x", 10000)
+	actual, err := ingestLines(t, "abc\r\n"+long+"\r\n", 10000)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", long}, actual)
+
+	actual, err = ingestLines(t, "abc\n"+long+"x\r\ndef\n", 10000)
+	require.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+	assert.Equal(t, 10000, err.(*stuffed.ErrRecordTooLarge).Limit)
+	assert.Equal(t, []string{"abc"}, actual)
+
+	actual, err = ingestLines(t, "abc\ndefg", 3)
+	require.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+	assert.Equal(t, []string{"abc"}, actual)
+}
@@ -0,0 +1,23 @@
+package stuffed
+
+import (
+	"bytes"
+)
+
+// AnalyzeDelimiterFrequency counts how many times each of several candidate
+// two-byte delimiters occurs in a representative sample of decoded record
+// content.  Every occurrence of the delimiter in a record's content forces the
+// encoder to end a run early and start a new one, so the candidate with the
+// lowest count is the one that will produce the fewest, longest runs for data
+// like the sample, which are cheaper to decode and compare.  (It won't change
+// the encoded size, though: each occurrence of a two-byte delimiter is replaced
+// by a run header of the same size.  Only one-byte delimiters make the encoding
+// grow.)  We count non-overlapping occurrences, since that's how the encoder
+// finds them.
+func AnalyzeDelimiterFrequency(sample []byte, candidates [][2]byte) map[[2]byte]int {
+	result := make(map[[2]byte]int, len(candidates))
+	for _, candidate := range candidates {
+		result[candidate] = bytes.Count(sample, candidate[:])
+	}
+	return result
+}
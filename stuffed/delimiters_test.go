@@ -0,0 +1,26 @@
+package stuffed_test
+
+import (
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeDelimiterFrequency(t *testing.T) {
+	sample := []byte("abc\xfe\xfdabc\xfe\xfd\xfe\xfd\x00\x00\x00")
+	counts := stuffed.AnalyzeDelimiterFrequency(sample, [][2]byte{
+		{0xfe, 0xfd},
+		{0x00, 0x00},
+		{'b', 'c'},
+		{0xff, 0xff},
+	})
+	assert.Equal(t, map[[2]byte]int{
+		{0xfe, 0xfd}: 3,
+		{0x00, 0x00}: 1,
+		{'b', 'c'}:   2,
+		{0xff, 0xff}: 0,
+	}, counts)
+
+	assert.Empty(t, stuffed.AnalyzeDelimiterFrequency(sample, nil))
+}
@@ -0,0 +1,96 @@
+package stuffed
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// distinctPrecision is the number of hash bits that EstimateDistinctKeys uses
+// to choose a register.  With 2^14 registers, the estimate has a standard error
+// of about 0.8%, and the estimator uses 16KiB of memory no matter how large the
+// list is.
+const distinctPrecision = 14
+
+// EstimateDistinctKeys estimates the number of distinct keys in a buffer
+// containing a list of delimited stuffed records, using a HyperLogLog sketch.
+// (If keyFn is nil, the key is the record's entire decoded content.)  It uses a
+// fixed amount of memory regardless of the size of the list, and doesn't
+// require the list to be sorted.  When keyFn is nil, we hash each record's
+// content straight out of its runs, without decoding it into a buffer.
+func EstimateDistinctKeys(encodedList []byte, keyFn KeyFunc) (uint64, error) {
+	var registers [1 << distinctPrecision]uint8
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	h := fnv.New64a()
+	var s Scanner
+	s.Reset(encodedList)
+	for s.Next() {
+		h.Reset()
+		if keyFn == nil {
+			r := newChunkReader(s.Encoded())
+			for {
+				chunk, ok, err := r.next()
+				if err != nil {
+					return 0, err
+				}
+				if !ok {
+					break
+				}
+				h.Write(chunk)
+			}
+		} else {
+			decoded.Reset()
+			if err := s.Decode(decoded); err != nil {
+				return 0, err
+			}
+			h.Write(keyFn(decoded.Bytes()))
+		}
+
+		hash := mixHash(h.Sum64())
+		register := hash >> (64 - distinctPrecision)
+		// Make sure there's always a 1 bit to find, so that the rank is
+		// bounded even when the remaining hash bits are all zero.
+		rest := hash<<distinctPrecision | 1<<(distinctPrecision-1)
+		rank := uint8(bits.LeadingZeros64(rest) + 1)
+		if rank > registers[register] {
+			registers[register] = rank
+		}
+	}
+	return estimateCardinality(registers[:]), nil
+}
+
+// mixHash scrambles the bits of an FNV hash, whose high bits aren't
+// distributed well enough on their own for HyperLogLog.  (This is the
+// finalizer from MurmurHash3.)
+func mixHash(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// estimateCardinality turns a set of HyperLogLog registers into a cardinality
+// estimate.
+func estimateCardinality(registers []uint8) uint64 {
+	m := float64(len(registers))
+	sum := 0.0
+	zeros := 0
+	for _, rank := range registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// Small cardinalities are estimated more accurately by counting how many
+	// registers are still empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
@@ -0,0 +1,42 @@
+package stuffed_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateDistinctKeys(t *testing.T) {
+	estimate, err := stuffed.EstimateDistinctKeys(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), estimate)
+
+	encoded := encodeList([]string{"a 1", "a 2", "b 1", "c 1", "a 3", "b 2"})
+	estimate, err = stuffed.EstimateDistinctKeys(encoded, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), estimate)
+	estimate, err = stuffed.EstimateDistinctKeys(encoded, keyBeforeSpace)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), estimate)
+
+	// Each key appears three times, and some keys contain delimiters.
+	var inputList []string
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 50000; j++ {
+			inputList = append(inputList, fmt.Sprintf("key\xfe\xfd%d %d", j, i))
+		}
+	}
+	encoded = encodeList(inputList)
+	estimate, err = stuffed.EstimateDistinctKeys(encoded, keyBeforeSpace)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 50000, estimate, 0.03)
+	estimate, err = stuffed.EstimateDistinctKeys(encoded, nil)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 150000, estimate, 0.03)
+
+	_, err = stuffed.EstimateDistinctKeys([]byte("\x03ab"), nil)
+	assert.Error(t, err)
+}
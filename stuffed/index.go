@@ -0,0 +1,309 @@
+package stuffed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+var (
+	// InvalidPostingList is the error that is returned when an inverted index
+	// contains a malformed posting list.
+	InvalidPostingList = errors.New("Invalid posting list")
+
+	// InvalidIndexEntry is the error that is returned when a numeric index
+	// contains a malformed entry, or one that doesn't point at a record.
+	InvalidIndexEntry = errors.New("Invalid index entry")
+)
+
+// Tokenizer extracts the terms from the decoded content of a record, calling
+// emit once for each term.  It's fine to emit the same term more than once.
+// Neither decoded nor any emitted term can be retained after the call returns.
+type Tokenizer func(decoded []byte, emit func(term []byte))
+
+// BuildInvertedIndex builds an inverted index over a buffer containing a list of
+// delimited stuffed records.  We decode each record and pass it to tokenize; the
+// result maps each term to the sorted list of offsets of the records that
+// contain that term.  The index is itself a sorted list of stuffed records (one
+// per term), so you can store it alongside the original list and query it with
+// LookupPostings and IntersectPostings.
+func BuildInvertedIndex(encodedList []byte, tokenize Tokenizer) ([]byte, error) {
+	info, err := NewListInfo(encodedList)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make(map[string][]int)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	for i := 0; i < info.Len(); i++ {
+		decoded.Reset()
+		if err := Decode(info.Record(i), decoded); err != nil {
+			return nil, err
+		}
+		offset := info.Offset(i)
+		tokenize(decoded.Bytes(), func(term []byte) {
+			list := postings[string(term)]
+			if len(list) > 0 && list[len(list)-1] == offset {
+				return
+			}
+			postings[string(term)] = append(list, offset)
+		})
+	}
+
+	var builder RecordBuilder
+	var delta [binary.MaxVarintLen64]byte
+	for term, offsets := range postings {
+		builder.Write(postingKey(term))
+		previous := 0
+		for _, offset := range offsets {
+			n := binary.PutUvarint(delta[:], uint64(offset-previous))
+			builder.Write(delta[:n])
+			previous = offset
+		}
+		builder.FinishRecord()
+	}
+	builder.Sort()
+	var index bytes.Buffer
+	builder.Encode(&index)
+	return index.Bytes(), nil
+}
+
+// postingKey returns the prefix of the posting list record for a term.  We
+// length-prefix the term so that no term's key is a prefix of another's.
+func postingKey(term string) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], uint64(len(term)))
+	return append(scratch[:n], term...)
+}
+
+// LookupPostings returns the sorted offsets of the records that contain a term,
+// using an inverted index created by BuildInvertedIndex.
+func LookupPostings(index []byte, term []byte) ([]int, error) {
+	key := postingKey(string(term))
+	matching, err := FindRecordsWithPrefix(index, key)
+	if err != nil || matching == nil {
+		return nil, err
+	}
+
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	if err := Decode(matching, decoded); err != nil {
+		return nil, err
+	}
+	encodedOffsets := decoded.Bytes()[len(key):]
+	var offsets []int
+	previous := 0
+	for len(encodedOffsets) > 0 {
+		delta, n := binary.Uvarint(encodedOffsets)
+		if n <= 0 {
+			return nil, InvalidPostingList
+		}
+		encodedOffsets = encodedOffsets[n:]
+		previous += int(delta)
+		offsets = append(offsets, previous)
+	}
+	return offsets, nil
+}
+
+// IntersectPostings returns the sorted offsets of the records that contain all
+// of the given terms, using an inverted index created by BuildInvertedIndex.
+func IntersectPostings(index []byte, terms [][]byte) ([]int, error) {
+	var result []int
+	for i, term := range terms {
+		offsets, err := LookupPostings(index, term)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			result = offsets
+			continue
+		}
+
+		var intersection []int
+		for _, offset := range result {
+			j := sort.SearchInts(offsets, offset)
+			if j < len(offsets) && offsets[j] == offset {
+				intersection = append(intersection, offset)
+			}
+		}
+		result = intersection
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+// NumericExtractor extracts a numeric field from the decoded content of a
+// record.  It returns false if the record doesn't have the field, in which case
+// the record is left out of the index.
+type NumericExtractor func(decoded []byte) (int64, bool)
+
+// numericIndexEntryLength is the length of each decoded entry in a numeric
+// index: an 8-byte order-preserving encoding of the value, followed by the
+// 8-byte offset of the primary record.
+const numericIndexEntryLength = 16
+
+// numericKey encodes a signed value so that the encoded bytes sort in the same
+// order as the values.
+func numericKey(value int64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], uint64(value)^(1<<63))
+	return key[:]
+}
+
+// BuildNumericIndex builds a secondary index over a numeric field of the records
+// in a buffer containing a list of delimited stuffed records.  The result is a
+// sorted list of stuffed records, one per indexed record, that you can query
+// with NumericRange.
+func BuildNumericIndex(encodedList []byte, extract NumericExtractor) ([]byte, error) {
+	info, err := NewListInfo(encodedList)
+	if err != nil {
+		return nil, err
+	}
+
+	var builder RecordBuilder
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var offset [8]byte
+	for i := 0; i < info.Len(); i++ {
+		decoded.Reset()
+		if err := Decode(info.Record(i), decoded); err != nil {
+			return nil, err
+		}
+		value, ok := extract(decoded.Bytes())
+		if !ok {
+			continue
+		}
+		builder.Write(numericKey(value))
+		binary.BigEndian.PutUint64(offset[:], uint64(info.Offset(i)))
+		builder.Write(offset[:])
+		builder.FinishRecord()
+	}
+	builder.Sort()
+	var index bytes.Buffer
+	builder.Encode(&index)
+	return index.Bytes(), nil
+}
+
+// NumericRange uses a numeric index created by BuildNumericIndex to find the
+// records in encodedList whose indexed field is between min and max,
+// inclusive.  It returns the encoded content of each of those records, ordered
+// by the indexed field.  We binary search the index for the matching entries,
+// just like FindRecordsWithPrefix, so we only look at O(log n) entries outside
+// of the range.
+func NumericRange(index, encodedList []byte, min, max int64) ([][]byte, error) {
+	minKey := numericKey(min)
+	maxKey := numericKey(max)
+	first, err := searchRecords(index, func(record []byte) (bool, error) {
+		cmp, err := CompareEncodedPrefix(record, minKey)
+		return cmp >= 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	last, err := searchRecords(index, func(record []byte) (bool, error) {
+		cmp, err := CompareEncodedPrefix(record, maxKey)
+		return cmp > 0, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if last <= first {
+		return nil, nil
+	}
+
+	var result [][]byte
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var s Scanner
+	s.Reset(index[first:last])
+	for s.Next() {
+		decoded.Reset()
+		if err := s.Decode(decoded); err != nil {
+			return nil, err
+		}
+		if decoded.Len() != numericIndexEntryLength {
+			return nil, InvalidIndexEntry
+		}
+		// Check the offset before converting it, so that a corrupt entry
+		// can't produce a negative offset.
+		rawOffset := binary.BigEndian.Uint64(decoded.Bytes()[8:])
+		if rawOffset >= uint64(len(encodedList)) {
+			return nil, InvalidIndexEntry
+		}
+		offset := int(rawOffset)
+		if !IsStartOfRecord(encodedList, offset) {
+			return nil, InvalidIndexEntry
+		}
+		end := FindDelimiter(encodedList[offset:])
+		if end == -1 {
+			end = len(encodedList)
+		} else {
+			end += offset
+		}
+		result = append(result, encodedList[offset:end])
+	}
+	return result, nil
+}
+
+// searchRecords binary searches a buffer containing a list of delimited stuffed
+// records, returning the offset of the first record for which pred returns
+// true.  (Or the offset of the end of the last record, if there isn't one.)
+// pred must return false for every record before that one, and true for every
+// record after it.  Like FindRecordsWithPrefix, we find the records to test by
+// looking for delimiters, without needing a record table.
+func searchRecords(encodedList []byte, pred func(encoded []byte) (bool, error)) (int, error) {
+	// min always points at the beginning of an encoded record.  max always
+	// points at the end of one.
+	min := 0
+	max := len(encodedList)
+	for bytes.HasPrefix(encodedList[min:max], delimiterBytes) {
+		min += delimiterLength
+	}
+	for bytes.HasSuffix(encodedList[min:max], delimiterBytes) {
+		max -= delimiterLength
+	}
+
+	result := max
+	for max > min {
+		// Jump to the middle of the remainder of the buffer, then find the
+		// enclosing record, skipping over any empty records.
+		mid := (max + min) / 2
+		recordStart := min
+		if index := bytes.LastIndex(encodedList[min:mid], delimiterBytes); index != -1 {
+			recordStart += index + delimiterLength
+		}
+		for bytes.HasPrefix(encodedList[recordStart:max], delimiterBytes) {
+			recordStart += delimiterLength
+		}
+		recordEnd := max
+		if index := bytes.Index(encodedList[recordStart:max], delimiterBytes); index != -1 {
+			recordEnd = recordStart + index
+		}
+
+		ok, err := pred(encodedList[recordStart:recordEnd])
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			result = recordStart
+			max = recordStart
+			for bytes.HasSuffix(encodedList[min:max], delimiterBytes) {
+				max -= delimiterLength
+			}
+		} else {
+			min = recordEnd
+			for bytes.HasPrefix(encodedList[min:max], delimiterBytes) {
+				min += delimiterLength
+			}
+		}
+	}
+	return result, nil
+}
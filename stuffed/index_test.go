@@ -0,0 +1,137 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func splitWords(decoded []byte, emit func(term []byte)) {
+	for _, word := range bytes.Fields(decoded) {
+		emit(word)
+	}
+}
+
+func TestInvertedIndex(t *testing.T) {
+	encoded := encodeList([]string{
+		"the quick brown fox",
+		"the lazy dog",
+		"quick quick dog",
+		"\xfe\xfd fox",
+	})
+	info, err := stuffed.NewListInfo(encoded)
+	require.NoError(t, err)
+	offset := func(i int) int { return info.Offset(i) }
+
+	index, err := stuffed.BuildInvertedIndex(encoded, splitWords)
+	require.NoError(t, err)
+
+	lookups := map[string][]int{
+		"the":        {offset(0), offset(1)},
+		"quick":      {offset(0), offset(2)},
+		"dog":        {offset(1), offset(2)},
+		"fox":        {offset(0), offset(3)},
+		"\xfe\xfd":   {offset(3)},
+		"qu":         nil,
+		"quickly":    nil,
+		"elephant":   nil,
+		"brown":      {offset(0)},
+		"lazy":       {offset(1)},
+		"\xfe\xfdxx": nil,
+	}
+	for term, expected := range lookups {
+		actual, err := stuffed.LookupPostings(index, []byte(term))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, term)
+	}
+
+	actual, err := stuffed.IntersectPostings(index, [][]byte{[]byte("quick"), []byte("dog")})
+	require.NoError(t, err)
+	assert.Equal(t, []int{offset(2)}, actual)
+
+	actual, err = stuffed.IntersectPostings(index, [][]byte{[]byte("the"), []byte("cat")})
+	require.NoError(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestNumericIndex(t *testing.T) {
+	inputList := []string{"5 e", "-3 c", "10 f", "x", "0 d", "5 e2", "-20 a"}
+	encoded := encodeList(inputList)
+	extract := func(decoded []byte) (int64, bool) {
+		var value int64
+		var rest string
+		_, err := fmt.Sscanf(string(decoded), "%d %s", &value, &rest)
+		return value, err == nil
+	}
+	index, err := stuffed.BuildNumericIndex(encoded, extract)
+	require.NoError(t, err)
+
+	ranges := []struct {
+		min, max int64
+		expected []string
+	}{
+		{math.MinInt64, math.MaxInt64, []string{"-20 a", "-3 c", "0 d", "5 e", "5 e2", "10 f"}},
+		{-3, 5, []string{"-3 c", "0 d", "5 e", "5 e2"}},
+		{-2, 4, []string{"0 d"}},
+		{5, 5, []string{"5 e", "5 e2"}},
+		{11, 20, []string{}},
+		{4, -4, []string{}},
+	}
+	for _, r := range ranges {
+		records, err := stuffed.NumericRange(index, encoded, r.min, r.max)
+		require.NoError(t, err)
+		assert.Equal(t, r.expected, decodeAll(t, records))
+	}
+}
+
+func TestNumericIndexCorruptEntry(t *testing.T) {
+	encoded := encodeList([]string{"abc"})
+	for _, offset := range []uint64{1 << 63, math.MaxUint64, uint64(len(encoded))} {
+		var entry [16]byte
+		binary.BigEndian.PutUint64(entry[:8], 1<<63)
+		binary.BigEndian.PutUint64(entry[8:], offset)
+		index := encodeList([]string{string(entry[:])})
+		_, err := stuffed.NumericRange(index, encoded, math.MinInt64, math.MaxInt64)
+		assert.Equal(t, stuffed.InvalidIndexEntry, err)
+	}
+}
+
+func TestNumericIndexLarge(t *testing.T) {
+	// Include values whose index keys contain delimiter bytes, and enough
+	// entries that the binary search has to skip over most of them.
+	var inputList []string
+	for value := -500; value < 500; value++ {
+		inputList = append(inputList, fmt.Sprintf("%d", value*0xfd))
+	}
+	encoded := encodeList(inputList)
+	extract := func(decoded []byte) (int64, bool) {
+		var value int64
+		_, err := fmt.Sscanf(string(decoded), "%d", &value)
+		return value, err == nil
+	}
+	index, err := stuffed.BuildNumericIndex(encoded, extract)
+	require.NoError(t, err)
+
+	for _, r := range []struct{ min, max int64 }{
+		{math.MinInt64, math.MaxInt64},
+		{-0xfd * 3, 0xfd * 7},
+		{0xfd*400 + 1, 0xfd*401 - 1},
+		{0xfd * 499, math.MaxInt64},
+	} {
+		expected := []string{}
+		for value := -500; value < 500; value++ {
+			if int64(value*0xfd) >= r.min && int64(value*0xfd) <= r.max {
+				expected = append(expected, fmt.Sprintf("%d", value*0xfd))
+			}
+		}
+		records, err := stuffed.NumericRange(index, encoded, r.min, r.max)
+		require.NoError(t, err)
+		assert.Equal(t, expected, decodeAll(t, records))
+	}
+}
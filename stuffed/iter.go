@@ -0,0 +1,46 @@
+//go:build go1.23
+
+package stuffed
+
+import (
+	"bytes"
+	"iter"
+)
+
+// Records returns an iterator over the encoded content of each record in a
+// buffer of delimited stuffed records, just like a Scanner.  Finding the
+// records can't fail, so the error is always nil; it's there so that Records
+// and DecodedRecords can be used interchangeably.
+func Records(encodedList []byte) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		var s Scanner
+		s.Reset(encodedList)
+		for s.Next() {
+			if !yield(s.Encoded(), nil) {
+				return
+			}
+		}
+	}
+}
+
+// DecodedRecords returns an iterator over the decoded content of each record
+// in a buffer of delimited stuffed records.  The decoded content is only valid
+// until the next iteration.  If a record can't be decoded, the iterator yields
+// the error and stops.
+func DecodedRecords(encodedList []byte) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		var decoded bytes.Buffer
+		var s Scanner
+		s.Reset(encodedList)
+		for s.Next() {
+			decoded.Reset()
+			if err := s.Decode(&decoded); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(decoded.Bytes(), nil) {
+				return
+			}
+		}
+	}
+}
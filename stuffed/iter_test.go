@@ -0,0 +1,52 @@
+//go:build go1.23
+
+package stuffed_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordIterators(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	encoded := encodeList(inputList)
+
+	var actual []string
+	for record, err := range stuffed.Records(encoded) {
+		require.NoError(t, err)
+		actual = append(actual, string(record))
+	}
+	var expected []string
+	for _, tc := range shortTestCases {
+		expected = append(expected, tc.encoded)
+	}
+	assert.Equal(t, expected, actual)
+
+	actual = nil
+	for record, err := range stuffed.DecodedRecords(encoded) {
+		require.NoError(t, err)
+		actual = append(actual, string(record))
+	}
+	assert.Equal(t, inputList, actual)
+
+	// Stopping early works.
+	actual = nil
+	for record, err := range stuffed.DecodedRecords(encoded) {
+		require.NoError(t, err)
+		actual = append(actual, string(record))
+		if len(actual) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, inputList[:2], actual)
+
+	var errs []error
+	for _, err := range stuffed.DecodedRecords([]byte("\x03abc\xfe\xfd\x03ab\xfe\xfd\x00")) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{nil, io.EOF}, errs)
+}
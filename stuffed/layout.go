@@ -0,0 +1,190 @@
+package stuffed
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// InvalidLayoutTarget is the error that is returned when you try to scan a
+	// record into something other than a pointer to the struct type that a
+	// Layout was created for.
+	InvalidLayoutTarget = errors.New("Invalid layout target")
+)
+
+// Layout describes how to extract the fields of a struct from fixed-offset
+// ranges of a stuffed record's decoded content.  You describe the layout using
+// struct tags of the form `stuffed:"offset,length"`; fields without a tag are
+// ignored.  Tagged fields can be:
+//
+//   - unsigned or signed integers, which are decoded as big-endian values, and
+//     whose length must match the size of the type
+//   - bools, which must have a length of 1, and are true if the byte is nonzero
+//   - strings and byte slices, which can have any length
+//   - byte arrays, whose length must match the size of the array
+//
+// Scanning a record only decodes the ranges that the layout needs.
+type Layout struct {
+	structType reflect.Type
+	fields     []int
+	ranges     []Range
+}
+
+// NewLayout creates a Layout from the struct tags of a struct type.  You can
+// pass in either a struct value or a pointer to one.
+func NewLayout(structValue interface{}) (*Layout, error) {
+	t := reflect.TypeOf(structValue)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, InvalidLayoutTarget
+	}
+
+	layout := &Layout{structType: t}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("stuffed")
+		if !ok {
+			continue
+		}
+		if field.PkgPath != "" {
+			return nil, fmt.Errorf("Invalid layout for field %s: field is not exported", field.Name)
+		}
+		rng, err := parseLayoutTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid layout for field %s: %s", field.Name, err)
+		}
+		if err := checkLayoutField(field.Type, rng.To-rng.From); err != nil {
+			return nil, fmt.Errorf("Invalid layout for field %s: %s", field.Name, err)
+		}
+		layout.fields = append(layout.fields, i)
+		layout.ranges = append(layout.ranges, rng)
+	}
+	return layout, nil
+}
+
+func parseLayoutTag(tag string) (Range, error) {
+	parts := strings.Split(tag, ",")
+	if len(parts) != 2 {
+		return Range{}, errors.New("tag must have the form \"offset,length\"")
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Range{}, err
+	}
+	length, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Range{}, err
+	}
+	if offset < 0 || length < 0 {
+		return Range{}, errors.New("offset and length cannot be negative")
+	}
+	return Range{From: offset, To: offset + length}, nil
+}
+
+func checkLayoutField(t reflect.Type, length int) error {
+	switch t.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if length != int(t.Size()) {
+			return fmt.Errorf("length %d doesn't match size of %s", length, t)
+		}
+	case reflect.Bool:
+		if length != 1 {
+			return fmt.Errorf("length %d doesn't match size of %s", length, t)
+		}
+	case reflect.String:
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+	case reflect.Array:
+		if t.Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+		if length != t.Len() {
+			return fmt.Errorf("length %d doesn't match size of %s", length, t)
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return nil
+}
+
+// Scan extracts the fields described by the layout from a stuffed record, and
+// stores them into dst, which must be a pointer to the layout's struct type.
+func (l *Layout) Scan(encoded []byte, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != l.structType {
+		return InvalidLayoutTarget
+	}
+	v = v.Elem()
+
+	values, err := ProjectFields(encoded, l.ranges)
+	if err != nil {
+		return err
+	}
+	for i, fieldIndex := range l.fields {
+		field := v.Field(fieldIndex)
+		value := values[i]
+		switch field.Kind() {
+		case reflect.Uint8:
+			field.SetUint(uint64(value[0]))
+		case reflect.Uint16:
+			field.SetUint(uint64(binary.BigEndian.Uint16(value)))
+		case reflect.Uint32:
+			field.SetUint(uint64(binary.BigEndian.Uint32(value)))
+		case reflect.Uint64:
+			field.SetUint(binary.BigEndian.Uint64(value))
+		case reflect.Int8:
+			field.SetInt(int64(int8(value[0])))
+		case reflect.Int16:
+			field.SetInt(int64(int16(binary.BigEndian.Uint16(value))))
+		case reflect.Int32:
+			field.SetInt(int64(int32(binary.BigEndian.Uint32(value))))
+		case reflect.Int64:
+			field.SetInt(int64(binary.BigEndian.Uint64(value)))
+		case reflect.Bool:
+			field.SetBool(value[0] != 0)
+		case reflect.String:
+			field.SetString(string(value))
+		case reflect.Slice:
+			field.SetBytes(value)
+		case reflect.Array:
+			// We copy each element separately, since reflect.Copy would
+			// require the array's element type to be exactly byte.
+			for j := range value {
+				field.Index(j).SetUint(uint64(value[j]))
+			}
+		}
+	}
+	return nil
+}
+
+// layouts caches the Layout for each struct type used with ScanStruct.
+var layouts sync.Map
+
+// ScanStruct extracts fields from a stuffed record into the struct that dst
+// points to, using the struct's `stuffed:"offset,length"` tags.  (See Layout
+// for details.)  The layout for each struct type is computed once and cached.
+func ScanStruct(encoded []byte, dst interface{}) error {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return InvalidLayoutTarget
+	}
+	cached, ok := layouts.Load(t.Elem())
+	if !ok {
+		layout, err := NewLayout(dst)
+		if err != nil {
+			return err
+		}
+		cached, _ = layouts.LoadOrStore(t.Elem(), layout)
+	}
+	return cached.(*Layout).Scan(encoded, dst)
+}
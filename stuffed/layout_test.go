@@ -0,0 +1,107 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type layoutHeader struct {
+	Version  uint8   `stuffed:"0,1"`
+	Flags    bool    `stuffed:"1,1"`
+	Length   uint16  `stuffed:"2,2"`
+	Delta    int32   `stuffed:"4,4"`
+	Sequence uint64  `stuffed:"8,8"`
+	Tag      [2]byte `stuffed:"16,2"`
+	Name     string  `stuffed:"18,5"`
+	Payload  []byte  `stuffed:"20,3"`
+	Ignored  int
+}
+
+func TestScanStruct(t *testing.T) {
+	decoded := []byte{
+		0x07,
+		0x01,
+		0x01, 0x02,
+		0xff, 0xff, 0xff, 0xfe,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00,
+		0xfe, 0xfd,
+		'a', 'b', 'c', 'd', 'e',
+		'f', 'g', 'h',
+	}
+	var encoded bytes.Buffer
+	stuffed.Encode(decoded, &encoded)
+
+	var header layoutHeader
+	header.Ignored = 42
+	require.NoError(t, stuffed.ScanStruct(encoded.Bytes(), &header))
+	assert.Equal(t, layoutHeader{
+		Version:  7,
+		Flags:    true,
+		Length:   0x0102,
+		Delta:    -2,
+		Sequence: 256,
+		Tag:      [2]byte{0xfe, 0xfd},
+		Name:     "abcde",
+		Payload:  []byte("cde"),
+		Ignored:  42,
+	}, header)
+
+	// A record that's too short for the layout
+	encoded.Reset()
+	stuffed.Encode(decoded[:10], &encoded)
+	assert.Equal(t, stuffed.InvalidRange, stuffed.ScanStruct(encoded.Bytes(), &header))
+
+	assert.Equal(t, stuffed.InvalidLayoutTarget, stuffed.ScanStruct(encoded.Bytes(), header))
+
+	// An empty field past the end of the record
+	var empty struct {
+		Name string `stuffed:"1000,0"`
+	}
+	assert.Equal(t, stuffed.InvalidRange, stuffed.ScanStruct(encoded.Bytes(), &empty))
+}
+
+type layoutByte byte
+
+func TestScanNamedByteArray(t *testing.T) {
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte("abcd"), &encoded)
+
+	var tagged struct {
+		Tag [4]layoutByte `stuffed:"0,4"`
+	}
+	require.NoError(t, stuffed.ScanStruct(encoded.Bytes(), &tagged))
+	assert.Equal(t, [4]layoutByte{'a', 'b', 'c', 'd'}, tagged.Tag)
+}
+
+func TestInvalidLayouts(t *testing.T) {
+	var wrongSize struct {
+		Value uint32 `stuffed:"0,2"`
+	}
+	_, err := stuffed.NewLayout(&wrongSize)
+	assert.Error(t, err)
+
+	var badTag struct {
+		Value uint32 `stuffed:"0"`
+	}
+	_, err = stuffed.NewLayout(badTag)
+	assert.Error(t, err)
+
+	var unsupported struct {
+		Value float64 `stuffed:"0,8"`
+	}
+	_, err = stuffed.NewLayout(unsupported)
+	assert.Error(t, err)
+
+	var unexported struct {
+		value uint8 `stuffed:"0,1"`
+	}
+	_, err = stuffed.NewLayout(unexported)
+	assert.Error(t, err)
+
+	_, err = stuffed.NewLayout(42)
+	assert.Equal(t, stuffed.InvalidLayoutTarget, err)
+}
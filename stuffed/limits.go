@@ -0,0 +1,145 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxRecordSize is a reasonable limit on the decoded size of a single
+// record, for producers and consumers that don't have a more specific policy.
+// Using the same limit on both sides ensures that consumers can read
+// everything that producers are allowed to write.
+const DefaultMaxRecordSize = 64 << 20
+
+var (
+	// TooManyRuns is the error that is returned when a stuffed record contains
+	// more runs than allowed by a Limits.
+	TooManyRuns = errors.New("Record contains too many runs")
+)
+
+// ErrRecordTooLarge is the error that is returned when a record's decoded
+// content is larger than allowed by a Limits.
+type ErrRecordTooLarge struct {
+	// Size is the decoded size of the record.  When decoding, we stop as soon
+	// as we know the record is too large, so this might only be a lower bound.
+	Size int
+	// Limit is the maximum allowed size.
+	Limit int
+}
+
+func (e *ErrRecordTooLarge) Error() string {
+	return fmt.Sprintf("Record size %d exceeds limit of %d bytes", e.Size, e.Limit)
+}
+
+// Limits bounds the amount of work that we're willing to perform when decoding
+// a stuffed record that comes from an untrusted source.  A zero value for any
+// field means that there is no limit.
+type Limits struct {
+	// MaxRuns is the maximum number of runs (and therefore run headers) that a
+	// single record can contain.  A record consisting of many empty runs costs
+	// far more to decode than its encoded size would suggest.
+	MaxRuns int
+
+	// MaxRecordSize is the maximum decoded size of a single record.
+	MaxRecordSize int
+
+	// WarnFraction, if positive, is the fraction of each limit at which we
+	// start to warn about records that are approaching it.  For instance, 0.8
+	// warns about any record that uses at least 80% of any limit.
+	WarnFraction float64
+
+	// OnWarning is called for each record that satisfies the limits, but
+	// which crosses the warning threshold of any of them.  (This lets you
+	// notice, via metrics or alerts, when your data is about to start
+	// failing.)  It's called once for each limit that the record is close
+	// to.
+	OnWarning func(LimitWarning)
+}
+
+// LimitWarning describes a record that is approaching one of the limits in a
+// Limits.
+type LimitWarning struct {
+	// Limit is the name of the limit: "MaxRuns" or "MaxRecordSize".  (For a
+	// SortedWriter's quotas, it's "MaxBytes" or "MaxRecords".)
+	Limit string
+	// Used is how much of the limit the record uses.
+	Used int
+	// Max is the value of the limit.
+	Max int
+}
+
+// CheckRecord verifies that a decoded record satisfies the limits, so that
+// producers can enforce the same policy that consumers will enforce when
+// decoding.
+func (limits Limits) CheckRecord(record []byte) error {
+	if err := limits.checkSize(len(record)); err != nil {
+		return err
+	}
+	limits.warn(0, len(record))
+	return nil
+}
+
+// checkSize verifies that a record whose decoded content is at least size bytes
+// long satisfies the limits.
+func (limits Limits) checkSize(size int) error {
+	if limits.MaxRecordSize > 0 && size > limits.MaxRecordSize {
+		return &ErrRecordTooLarge{Size: size, Limit: limits.MaxRecordSize}
+	}
+	return nil
+}
+
+// warn calls OnWarning for each limit that a record with the given number of
+// runs and decoded size is close to.  The record must satisfy the limits.
+func (limits Limits) warn(runs, size int) {
+	if limits.OnWarning == nil || limits.WarnFraction <= 0 {
+		return
+	}
+	limits.warnIfClose("MaxRuns", runs, limits.MaxRuns)
+	limits.warnIfClose("MaxRecordSize", size, limits.MaxRecordSize)
+}
+
+func (limits Limits) warnIfClose(name string, used, max int) {
+	if max > 0 && float64(used) >= limits.WarnFraction*float64(max) {
+		limits.OnWarning(LimitWarning{Limit: name, Used: used, Max: max})
+	}
+}
+
+// DecodeWithLimits reads a binary record from an input buffer using the stuffed
+// records encoding, just like Decode, but returns an error as soon as the record
+// exceeds any of the given limits.  If the record satisfies the limits, but is
+// close to any of them, we call the limits' OnWarning callback.
+func DecodeWithLimits(encoded []byte, record *bytes.Buffer, limits Limits) error {
+	var c *Codec
+	return c.DecodeWithLimits(encoded, record, limits)
+}
+
+// DecodeLimit decodes a stuffed record into a buffer that you provide,
+// returning the number of bytes of decoded content.  We refuse to produce more
+// than max bytes of decoded content, returning an ErrRecordTooLarge error as
+// soon as we know that the record is too large.  If the record is within max,
+// but doesn't fit in dst, we return io.ErrShortBuffer.  Either way, this never
+// allocates, so you can use it to put a hard ceiling on the memory used to
+// decode untrusted input.  If we return an error, the content of dst is
+// unspecified.
+func DecodeLimit(encoded []byte, dst []byte, max int) (int, error) {
+	n := 0
+	r := newChunkReader(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return n, nil
+		}
+		if n+len(chunk) > max {
+			return 0, &ErrRecordTooLarge{Size: n + len(chunk), Limit: max}
+		}
+		if n+len(chunk) > len(dst) {
+			return 0, io.ErrShortBuffer
+		}
+		n += copy(dst[n:], chunk)
+	}
+}
@@ -0,0 +1,123 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeWithLimits(t *testing.T) {
+	// Without any limits, we should behave exactly like Decode.
+	for _, tc := range shortTestCases {
+		var buf bytes.Buffer
+		err := stuffed.DecodeWithLimits([]byte(tc.encoded), &buf, stuffed.Limits{})
+		require.NoError(t, err)
+		assert.Equal(t, buf.String(), string(tc.decoded))
+	}
+
+	// A record with three embedded delimiters has four runs.
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte(strings.Repeat("a\xfe\xfd", 3)), &encoded)
+	var buf bytes.Buffer
+	err := stuffed.DecodeWithLimits(encoded.Bytes(), &buf, stuffed.Limits{MaxRuns: 4})
+	require.NoError(t, err)
+	buf.Reset()
+	err = stuffed.DecodeWithLimits(encoded.Bytes(), &buf, stuffed.Limits{MaxRuns: 3})
+	assert.Equal(t, stuffed.TooManyRuns, err)
+}
+
+func TestMaxRecordSize(t *testing.T) {
+	limits := stuffed.Limits{MaxRecordSize: 5}
+	for _, tc := range shortTestCases {
+		var buf bytes.Buffer
+		err := stuffed.DecodeWithLimits([]byte(tc.encoded), &buf, limits)
+		if len(tc.decoded) <= limits.MaxRecordSize {
+			require.NoError(t, err)
+			assert.Equal(t, tc.decoded, buf.String())
+			assert.NoError(t, limits.CheckRecord([]byte(tc.decoded)))
+		} else {
+			require.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+			tooLarge := err.(*stuffed.ErrRecordTooLarge)
+			assert.Equal(t, limits.MaxRecordSize, tooLarge.Limit)
+			assert.True(t, tooLarge.Size > limits.MaxRecordSize)
+			assert.True(t, tooLarge.Size <= len(tc.decoded))
+			assert.Equal(t, &stuffed.ErrRecordTooLarge{Size: len(tc.decoded), Limit: 5}, limits.CheckRecord([]byte(tc.decoded)))
+		}
+	}
+}
+
+func TestLimitWarnings(t *testing.T) {
+	var warnings []stuffed.LimitWarning
+	limits := stuffed.Limits{
+		MaxRuns:       4,
+		MaxRecordSize: 10,
+		WarnFraction:  0.8,
+		OnWarning: func(warning stuffed.LimitWarning) {
+			warnings = append(warnings, warning)
+		},
+	}
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		codec    *stuffed.Codec
+		decoded  string
+		expected []stuffed.LimitWarning
+	}{
+		{stuffed.DefaultCodec, "abc", nil},
+		{stuffed.DefaultCodec, "abcdefgh", []stuffed.LimitWarning{{Limit: "MaxRecordSize", Used: 8, Max: 10}}},
+		{stuffed.DefaultCodec, "a\xfe\xfdb\xfe\xfdc\xfe\xfd", []stuffed.LimitWarning{
+			{Limit: "MaxRuns", Used: 4, Max: 4},
+			{Limit: "MaxRecordSize", Used: 9, Max: 10},
+		}},
+		{stuffed.DefaultCodec, "abcdefghijk", nil},
+		{codec, "abcdefgh", []stuffed.LimitWarning{{Limit: "MaxRecordSize", Used: 8, Max: 10}}},
+		{codec, "a\x00b\x00c\x00", []stuffed.LimitWarning{{Limit: "MaxRuns", Used: 4, Max: 4}}},
+	}
+	for _, tc := range testCases {
+		var encoded, buf bytes.Buffer
+		tc.codec.Encode([]byte(tc.decoded), &encoded)
+		warnings = nil
+		err := tc.codec.DecodeWithLimits(encoded.Bytes(), &buf, limits)
+		if len(tc.decoded) > limits.MaxRecordSize {
+			assert.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, tc.expected, warnings, "%q", tc.decoded)
+	}
+
+	warnings = nil
+	require.NoError(t, limits.CheckRecord([]byte("abcdefgh")))
+	assert.Equal(t, []stuffed.LimitWarning{{Limit: "MaxRecordSize", Used: 8, Max: 10}}, warnings)
+}
+
+func TestDecodeLimit(t *testing.T) {
+	dst := make([]byte, 1<<20)
+	for _, tc := range shortTestCases {
+		n, err := stuffed.DecodeLimit([]byte(tc.encoded), dst, len(dst))
+		require.NoError(t, err)
+		assert.Equal(t, tc.decoded, string(dst[:n]))
+
+		n, err = stuffed.DecodeLimit([]byte(tc.encoded), dst, len(tc.decoded))
+		require.NoError(t, err)
+		assert.Equal(t, len(tc.decoded), n)
+
+		if len(tc.decoded) > 0 {
+			_, err = stuffed.DecodeLimit([]byte(tc.encoded), dst, len(tc.decoded)-1)
+			require.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+			assert.Equal(t, len(tc.decoded)-1, err.(*stuffed.ErrRecordTooLarge).Limit)
+
+			_, err = stuffed.DecodeLimit([]byte(tc.encoded), dst[:len(tc.decoded)-1], len(dst))
+			assert.Equal(t, io.ErrShortBuffer, err)
+		}
+	}
+
+	_, err := stuffed.DecodeLimit([]byte("\x03ab"), dst, len(dst))
+	assert.Equal(t, io.EOF, err)
+}
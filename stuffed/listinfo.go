@@ -0,0 +1,166 @@
+package stuffed
+
+import (
+	"bytes"
+	"sort"
+)
+
+// ListInfo describes the structure of a buffer containing a list of delimited
+// stuffed records: where each record starts and ends, and how long each
+// record's decoded content is.  Computing this requires a full pass over the
+// buffer, but once you have it, the methods on ListInfo can answer queries
+// without searching for delimiters again.  This is worthwhile when you query
+// the same buffer many times.  A ListInfo knows which Codec the records were
+// encoded with, and uses it both to find each record and to compare and decode
+// their content.
+//
+// A ListInfo refers to the buffer that it was computed from.  If you modify the
+// buffer, you must call Reset to recompute the ListInfo.  Its query methods
+// only read from it, so many goroutines can query the same ListInfo at once,
+// but not while another goroutine is calling Reset.
+type ListInfo struct {
+	codec       *Codec
+	list        []byte
+	starts      []int
+	ends        []int
+	decodedLens []int
+}
+
+// NewListInfo computes a ListInfo for a buffer containing a list of delimited
+// stuffed records.  It returns an error if any of the records are malformed.
+// The records must use the default delimiter; use Codec.NewListInfo for
+// records that were encoded with some other Codec.
+func NewListInfo(encodedList []byte) (*ListInfo, error) {
+	return DefaultCodec.NewListInfo(encodedList)
+}
+
+// NewListInfo computes a ListInfo for a buffer containing a list of records
+// that were encoded with this Codec.  It returns an error if any of the records
+// are malformed.
+func (c *Codec) NewListInfo(encodedList []byte) (*ListInfo, error) {
+	if c.isDefault() {
+		c = nil
+	}
+	info := &ListInfo{codec: c}
+	if err := info.Reset(encodedList); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Reset recomputes a ListInfo for a new (or modified) buffer of delimited
+// stuffed records, which must use the same Codec as before.
+func (info *ListInfo) Reset(encodedList []byte) error {
+	info.list = encodedList
+	info.starts = info.starts[:0]
+	info.ends = info.ends[:0]
+	info.decodedLens = info.decodedLens[:0]
+
+	delim := info.codec.delim()
+	start := 0
+	for {
+		// Skip over any leading delimiters.
+		for bytes.HasPrefix(encodedList[start:], delim) {
+			start += len(delim)
+		}
+		if start == len(encodedList) {
+			return nil
+		}
+
+		end := len(encodedList)
+		index := info.codec.FindDelimiter(encodedList[start:])
+		if index != -1 {
+			end = start + index
+		}
+		length, err := info.codec.DecodedLen(encodedList[start:end])
+		if err != nil {
+			return err
+		}
+
+		info.starts = append(info.starts, start)
+		info.ends = append(info.ends, end)
+		info.decodedLens = append(info.decodedLens, length)
+		start = end
+	}
+}
+
+// Bytes returns the buffer that this ListInfo describes.
+func (info *ListInfo) Bytes() []byte {
+	return info.list
+}
+
+// Len returns the number of records in the list.
+func (info *ListInfo) Len() int {
+	return len(info.starts)
+}
+
+// Offset returns the offset of the i'th record within the buffer.
+func (info *ListInfo) Offset(i int) int {
+	return info.starts[i]
+}
+
+// Record returns the encoded content of the i'th record.
+func (info *ListInfo) Record(i int) []byte {
+	return info.list[info.starts[i]:info.ends[i]]
+}
+
+// DecodedLen returns the length of the decoded content of the i'th record.
+func (info *ListInfo) DecodedLen(i int) int {
+	return info.decodedLens[i]
+}
+
+// FindRecordsWithPrefix behaves just like the package-level
+// FindRecordsWithPrefix, but binary searches through the record table instead
+// of searching for delimiters.
+func (info *ListInfo) FindRecordsWithPrefix(prefix []byte) ([]byte, error) {
+	first, last, err := info.prefixBounds(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if first == last {
+		return nil, nil
+	}
+	return info.list[info.starts[first]:info.ends[last-1]], nil
+}
+
+// prefixBounds returns the range of record indices [first, last) whose decoded
+// content starts with prefix.  The list must be sorted.
+func (info *ListInfo) prefixBounds(prefix []byte) (int, int, error) {
+	// Every record starts with the empty prefix.
+	if len(prefix) == 0 {
+		return 0, info.Len(), nil
+	}
+
+	var searchErr error
+	compare := func(i int) int {
+		cmp, err := info.codec.CompareEncodedPrefix(info.Record(i), prefix)
+		if err != nil && searchErr == nil {
+			searchErr = err
+		}
+		return cmp
+	}
+
+	first := sort.Search(info.Len(), func(i int) bool {
+		return compare(i) >= 0
+	})
+	last := first + sort.Search(info.Len()-first, func(i int) bool {
+		return compare(first+i) > 0
+	})
+	if searchErr != nil {
+		return 0, 0, searchErr
+	}
+	return first, last, nil
+}
+
+// FilterBySize behaves just like the package-level FilterBySize, but uses the
+// precomputed decoded length of each record.
+func (info *ListInfo) FilterBySize(minDecoded, maxDecoded int) []byte {
+	var result bytes.Buffer
+	for i, length := range info.decodedLens {
+		if length >= minDecoded && length <= maxDecoded {
+			result.Write(info.Record(i))
+			info.codec.EncodeDelimiter(&result)
+		}
+	}
+	return result.Bytes()
+}
@@ -0,0 +1,147 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkListInfo(t require.TestingT, inputList []string, prefix string) {
+	sort.Strings(inputList)
+	var encoded bytes.Buffer
+	for _, input := range inputList {
+		stuffed.EncodeDelimiter(&encoded)
+		stuffed.Encode([]byte(input), &encoded)
+	}
+	stuffed.EncodeDelimiter(&encoded)
+
+	info, err := stuffed.NewListInfo(encoded.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, len(inputList), info.Len())
+	for i, input := range inputList {
+		assert.True(t, stuffed.IsStartOfRecord(encoded.Bytes(), info.Offset(i)))
+		assert.Equal(t, len(input), info.DecodedLen(i))
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.Decode(info.Record(i), &decoded))
+		assert.Equal(t, input, decoded.String())
+	}
+
+	expected, err := stuffed.FindRecordsWithPrefix(encoded.Bytes(), []byte(prefix))
+	require.NoError(t, err)
+	actual, err := info.FindRecordsWithPrefix([]byte(prefix))
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestListInfo(t *testing.T) {
+	for _, tc := range prefixTestCases {
+		checkListInfo(t, shortTestCaseInputs(), tc.prefix)
+	}
+	checkListInfo(t, []string{}, "")
+	checkListInfo(t, []string{"a", "b"}, "c")
+
+	encoded := encodeList(shortTestCaseInputs())
+	info, err := stuffed.NewListInfo(encoded)
+	require.NoError(t, err)
+	expected, err := stuffed.FilterBySize(encoded, 2, 300)
+	require.NoError(t, err)
+	assert.Equal(t, expected, info.FilterBySize(2, 300))
+
+	_, err = stuffed.NewListInfo([]byte("\x03abc\xfe\xfd\xff"))
+	assert.Equal(t, stuffed.InvalidRunLength, err)
+}
+
+func TestScannerWithListInfo(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	info, err := stuffed.NewListInfo(encodeList(inputList))
+	require.NoError(t, err)
+
+	var s stuffed.Scanner
+	s.ResetWithInfo(info)
+	actual := []string{}
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputList, actual)
+
+	// Resetting the scanner to a plain buffer should forget the ListInfo.
+	s.Reset(encodeList([]string{"abc"}))
+	actual = []string{}
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, []string{"abc"}, actual)
+}
+
+func TestCodecListInfo(t *testing.T) {
+	for _, delim := range codecDelimiters {
+		codec, err := stuffed.NewCodec([]byte(delim))
+		require.NoError(t, err)
+		inputList := codecInputs(delim)
+		sort.Strings(inputList)
+		var encoded bytes.Buffer
+		for _, input := range inputList {
+			codec.Encode([]byte(input), &encoded)
+			codec.EncodeDelimiter(&encoded)
+		}
+
+		info, err := codec.NewListInfo(encoded.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, len(inputList), info.Len())
+		for i, input := range inputList {
+			assert.Equal(t, len(input), info.DecodedLen(i))
+		}
+
+		for _, prefix := range []string{"", "abc", delim, "zzz"} {
+			expected, err := codec.FindRecordsWithPrefix(encoded.Bytes(), []byte(prefix))
+			require.NoError(t, err)
+			actual, err := info.FindRecordsWithPrefix([]byte(prefix))
+			require.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		}
+
+		s := codec.Scanner(nil)
+		s.ResetWithInfo(info)
+		actual := []string{}
+		for s.Next() {
+			var decoded bytes.Buffer
+			require.NoError(t, s.Decode(&decoded))
+			actual = append(actual, decoded.String())
+		}
+		assert.Equal(t, inputList, actual)
+
+		q := stuffed.Query{
+			Range:     stuffed.KeyRange{Start: []byte("abc"), End: []byte("d")},
+			Predicate: func(decoded []byte) bool { return len(decoded) != 3 },
+		}
+		records, err := q.ExecuteWithInfo(info)
+		require.NoError(t, err)
+		actual = []string{}
+		for _, record := range records {
+			var decoded bytes.Buffer
+			require.NoError(t, codec.Decode(record, &decoded))
+			actual = append(actual, decoded.String())
+		}
+		expected := []string{}
+		for _, input := range inputList {
+			if input >= "abc" && input < "d" && len(input) != 3 {
+				expected = append(expected, input)
+			}
+		}
+		assert.Equal(t, expected, actual)
+
+		// A Scanner can't use a ListInfo for some other Codec.
+		if delim != "\xfe\xfd" {
+			var defaultScanner stuffed.Scanner
+			assert.Panics(t, func() { defaultScanner.ResetWithInfo(info) })
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package stuffed
+
+import (
+	"bytes"
+	"sort"
+)
+
+// SplitList divides a buffer containing a list of delimited stuffed records
+// into consecutive parts, each of which ends cleanly on a record boundary.
+// Every part except the last is at least minPartSize bytes long, and ends with
+// the first delimiter that lets it reach that size.  Concatenating the parts
+// gives back the original buffer, and each part is a valid list on its own.
+// (This is useful for multipart uploads, which usually require every part
+// except the last to meet a minimum size.)
+func SplitList(encodedList []byte, minPartSize int) [][]byte {
+	var parts [][]byte
+	start := 0
+	for start < len(encodedList) {
+		if len(encodedList)-start <= minPartSize {
+			break
+		}
+
+		// Find the first delimiter that ends at or after the minimum part
+		// size.
+		search := start + minPartSize - delimiterLength
+		if search < start {
+			search = start
+		}
+		index := FindDelimiter(encodedList[search:])
+		if index == -1 {
+			break
+		}
+		end := search + index + delimiterLength
+		parts = append(parts, encodedList[start:end])
+		start = end
+	}
+	if start < len(encodedList) {
+		parts = append(parts, encodedList[start:])
+	}
+	return parts
+}
+
+// FilterBySize takes a buffer containing a list of delimited stuffed records,
+// and returns a new buffer containing only those records whose decoded length
+// is between minDecoded and maxDecoded, inclusive.  We compute each record's
+// decoded length from its run headers, and copy the encoded content of each
+// matching record verbatim, so none of the records are decoded.
+func FilterBySize(encodedList []byte, minDecoded, maxDecoded int) ([]byte, error) {
+	var result bytes.Buffer
+	var s Scanner
+	s.Reset(encodedList)
+	for s.Next() {
+		length, err := DecodedLen(s.Encoded())
+		if err != nil {
+			return nil, err
+		}
+		if length >= minDecoded && length <= maxDecoded {
+			result.Write(s.Encoded())
+			EncodeDelimiter(&result)
+		}
+	}
+	return result.Bytes(), nil
+}
+
+// MultiGet looks up several keys in a buffer containing a list of stuffed
+// records sorted by their decoded content.  For each key, the result maps the
+// key to the subset of the buffer containing the records that start with that
+// key, just like FindRecordsWithPrefix.  Keys without any matching records are
+// not included in the result.  We look up the keys in sorted order, and each
+// lookup only searches the part of the buffer after the previous key's
+// matches, so this is cheaper than looking up each key independently.
+func MultiGet(encodedList []byte, keys [][]byte) (map[string][]byte, error) {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	result := make(map[string][]byte)
+	remaining := encodedList
+	for _, key := range sorted {
+		matching, err := FindRecordsWithPrefix(remaining, key)
+		if err != nil {
+			return nil, err
+		}
+		if matching == nil {
+			continue
+		}
+		result[string(key)] = matching
+
+		// Every later key sorts at or after this one, so its matches can't
+		// start before this key's matches do.  matching is a subslice of
+		// remaining, so we can find its offset from their capacities.
+		remaining = remaining[cap(remaining)-cap(matching):]
+	}
+	return result, nil
+}
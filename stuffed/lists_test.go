@@ -0,0 +1,96 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeList(inputList []string) []byte {
+	var encoded bytes.Buffer
+	for _, input := range inputList {
+		stuffed.Encode([]byte(input), &encoded)
+		stuffed.EncodeDelimiter(&encoded)
+	}
+	return encoded.Bytes()
+}
+
+func TestSplitList(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	encoded := encodeList(inputList)
+	for _, minPartSize := range []int{0, 1, 2, 3, 10, 300, 100000} {
+		parts := stuffed.SplitList(encoded, minPartSize)
+		assert.Equal(t, encoded, bytes.Join(parts, nil))
+
+		var actual []string
+		for i, part := range parts {
+			if i < len(parts)-1 {
+				assert.True(t, len(part) >= minPartSize)
+			}
+			assert.True(t, bytes.HasSuffix(part, delimiter))
+			decoded, err := parseStrings(part)
+			require.NoError(t, err)
+			actual = append(actual, decoded...)
+		}
+		assert.Equal(t, inputList, actual)
+	}
+
+	assert.Empty(t, stuffed.SplitList(nil, 10))
+}
+
+func TestFilterBySize(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	encoded := encodeList(inputList)
+	sizes := [][2]int{
+		{0, 0},
+		{0, 3},
+		{2, 5},
+		{100, 300},
+		{300, 100000},
+		{100000, 200000},
+	}
+	for _, size := range sizes {
+		var expected []string
+		for _, input := range inputList {
+			if len(input) >= size[0] && len(input) <= size[1] {
+				expected = append(expected, input)
+			}
+		}
+
+		filtered, err := stuffed.FilterBySize(encoded, size[0], size[1])
+		require.NoError(t, err)
+		actual, err := parseStrings(filtered)
+		require.NoError(t, err)
+		if expected == nil {
+			assert.Empty(t, actual)
+		} else {
+			assert.Equal(t, expected, actual)
+		}
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	sort.Strings(inputList)
+	encoded := encodeList(inputList)
+
+	var keys [][]byte
+	for _, tc := range prefixTestCases {
+		keys = append(keys, []byte(tc.prefix))
+	}
+	keys = append(keys, []byte("zzz"), []byte("\xfe\xfd"), []byte("abc"))
+
+	result, err := stuffed.MultiGet(encoded, keys)
+	require.NoError(t, err)
+	for _, key := range keys {
+		expected, err := stuffed.FindRecordsWithPrefix(encoded, key)
+		require.NoError(t, err)
+		actual, ok := result[string(key)]
+		assert.Equal(t, expected != nil, ok)
+		assert.Equal(t, expected, actual)
+	}
+}
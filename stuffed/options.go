@@ -0,0 +1,216 @@
+package stuffed
+
+import (
+	"bytes"
+)
+
+// Option configures how a Reader, Scanner, or RecordBuilder encodes and
+// decodes records.  The same options work with all of them, so that producers
+// and consumers can share a single configuration.
+type Option func(*options)
+
+// options is the configuration built up from a list of Options.  The zero
+// value uses the default delimiter, with no limits, no checksums, and no
+// transform.
+type options struct {
+	codec     *Codec
+	limits    *Limits
+	checksum  bool
+	transform Transform
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCodec causes records to be encoded and decoded using a Codec, instead of
+// with the default delimiter.
+func WithCodec(codec *Codec) Option {
+	return func(o *options) {
+		if codec.isDefault() {
+			codec = nil
+		}
+		o.codec = codec
+	}
+}
+
+// WithLimits causes records to be decoded using DecodeWithLimits.  A Reader
+// also refuses to buffer any encoded record that's too large to satisfy the
+// limits.  Limits only apply when decoding, so they have no effect on a
+// RecordBuilder; use Limits.CheckRecord to enforce them when producing records.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.limits = &limits
+	}
+}
+
+// WithChecksum causes each record to carry a CRC32 checksum of its content, as
+// written by EncodeWithChecksum and verified by DecodeWithChecksum.
+func WithChecksum() Option {
+	return func(o *options) {
+		o.checksum = true
+	}
+}
+
+// WithTransform causes a Transform to be applied to the content of each record
+// before it's encoded, and undone after it's decoded.  If you also use
+// checksums, the checksum covers the transformed content, so that we detect
+// corruption before trying to undo the transform.  MaxRecordSize (or
+// DefaultMaxRecordSize, if you don't provide any limits) applies to the content
+// after undoing the transform, and we pass it to the transform's Invert method
+// so that it can stop as soon as it exceeds the limit.  We also refuse to undo
+// the transform for any record whose transformed content is larger than the
+// transform's MaxTransformedLen for that limit.  (In that case, the
+// ErrRecordTooLarge error describes the transformed content and its limit.)
+func WithTransform(transform Transform) Option {
+	return func(o *options) {
+		o.transform = transform
+	}
+}
+
+// maxEncodedLen returns the largest number of bytes that encode can produce for
+// an n-byte record.
+func (o *options) maxEncodedLen(n int) int {
+	if o.transform != nil {
+		n = o.transform.MaxTransformedLen(n)
+	}
+	if o.checksum {
+		n += checksumLength
+	}
+	return o.codec.MaxEncodedLen(n)
+}
+
+// encode writes a binary record into an output buffer according to the
+// options.
+func (o *options) encode(record []byte, dest *bytes.Buffer) {
+	if o.transform != nil {
+		record = o.transform.Apply(record)
+	}
+	if !o.checksum {
+		o.codec.Encode(record, dest)
+		return
+	}
+	scratch := getScratch()
+	defer putScratch(scratch)
+	scratch.raw = appendChecksum(append(scratch.raw, record...))
+	o.codec.Encode(scratch.raw, dest)
+}
+
+// decode reads a binary record from an input buffer according to the options.
+// If we're verifying checksums or undoing a transform, we leave the output
+// buffer unchanged on error, just like DecodeWithChecksum.
+func (o *options) decode(encoded []byte, dest *bytes.Buffer) error {
+	if o.limits == nil && !o.checksum && o.transform == nil {
+		return o.codec.Decode(encoded, dest)
+	}
+	if o.transform != nil {
+		return o.decodeTransformed(encoded, dest)
+	}
+
+	start := dest.Len()
+	var err error
+	if o.limits != nil {
+		limits := *o.limits
+		if o.checksum && limits.MaxRecordSize > 0 {
+			limits.MaxRecordSize += checksumLength
+		}
+		err = o.codec.DecodeWithLimits(encoded, dest, limits)
+	} else {
+		err = o.codec.Decode(encoded, dest)
+	}
+	if o.checksum {
+		if err == nil {
+			err = verifyChecksum(dest.Bytes()[start:])
+		}
+		if err != nil {
+			dest.Truncate(start)
+			return err
+		}
+		dest.Truncate(dest.Len() - checksumLength)
+	}
+	return err
+}
+
+// decodeTransformed decodes a record into a scratch buffer, and then undoes the
+// transform.  MaxRecordSize applies to the content after undoing the transform,
+// so when decoding the transformed content, we use the transform's bound for
+// that size instead.
+func (o *options) decodeTransformed(encoded []byte, dest *bytes.Buffer) error {
+	var limits Limits
+	if o.limits != nil {
+		limits = *o.limits
+	}
+	max := DefaultMaxRecordSize
+	if limits.MaxRecordSize > 0 {
+		max = limits.MaxRecordSize
+	}
+	inner := *o
+	inner.transform = nil
+	inner.limits = &limits
+	limits.MaxRecordSize = o.transform.MaxTransformedLen(max)
+	// We warn about the size of the content after undoing the transform below.
+	if onWarning := limits.OnWarning; onWarning != nil {
+		limits.OnWarning = func(warning LimitWarning) {
+			if warning.Limit != "MaxRecordSize" {
+				onWarning(warning)
+			}
+		}
+	}
+
+	scratch := getScratch()
+	defer putScratch(scratch)
+	if err := inner.decode(encoded, &scratch.a); err != nil {
+		return err
+	}
+	record, err := o.transform.Invert(scratch.a.Bytes(), max)
+	if err != nil {
+		return err
+	}
+	if len(record) > max {
+		// The transform should have caught this, but make sure.
+		return &ErrRecordTooLarge{Size: len(record), Limit: max}
+	}
+	if o.limits != nil {
+		o.limits.warn(0, len(record))
+	}
+	dest.Write(record)
+	return nil
+}
+
+// validate checks whether decode would accept a record, without keeping its
+// decoded content.  If we don't need to look at the record's content, we only
+// check its run headers.
+func (o *options) validate(encoded []byte) error {
+	if o.limits == nil && !o.checksum && o.transform == nil {
+		_, err := o.codec.DecodedLen(encoded)
+		return err
+	}
+	scratch := getScratch()
+	defer putScratch(scratch)
+	return o.decode(encoded, &scratch.a)
+}
+
+// comparator returns a function that compares two records that were encoded
+// according to the options, by their decoded content.  With the default
+// options, we can compare the records without decoding them.
+func (o *options) comparator() func(a, b []byte) (int, error) {
+	if o.codec == nil && !o.checksum && o.transform == nil {
+		return CompareEncoded
+	}
+	var decodedA, decodedB bytes.Buffer
+	return func(a, b []byte) (int, error) {
+		decodedA.Reset()
+		if err := o.decode(a, &decodedA); err != nil {
+			return 0, err
+		}
+		decodedB.Reset()
+		if err := o.decode(b, &decodedB); err != nil {
+			return 0, err
+		}
+		return bytes.Compare(decodedA.Bytes(), decodedB.Bytes()), nil
+	}
+}
@@ -0,0 +1,153 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkOptionsRoundTrip encodes some records with a RecordBuilder, and checks
+// that a Scanner and a Reader with the same options can decode them.
+func checkOptionsRoundTrip(t *testing.T, inputs []string, opts ...stuffed.Option) []byte {
+	rb := stuffed.NewRecordBuilder(opts...)
+	for _, input := range inputs {
+		rb.WriteString(input)
+		rb.FinishRecord()
+	}
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+
+	s := stuffed.NewScanner(encoded.Bytes(), opts...)
+	actual := []string{}
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, inputs, actual)
+
+	reader := stuffed.NewReader(bytes.NewReader(encoded.Bytes()), opts...)
+	actual = []string{}
+	for reader.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, reader.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, inputs, actual)
+	return encoded.Bytes()
+}
+
+func TestOptions(t *testing.T) {
+	inputs := shortTestCaseInputs()
+	assert.Equal(t, encodeList(inputs), checkOptionsRoundTrip(t, inputs))
+	assert.Equal(t, encodeList(inputs), checkOptionsRoundTrip(t, inputs, stuffed.WithCodec(stuffed.DefaultCodec)))
+
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	encoded := checkOptionsRoundTrip(t, inputs, stuffed.WithCodec(codec))
+	assert.Equal(t, len(inputs), bytes.Count(encoded, []byte{0x00}))
+
+	encoded = checkOptionsRoundTrip(t, inputs, stuffed.WithChecksum())
+	var expected bytes.Buffer
+	for _, input := range inputs {
+		stuffed.EncodeWithChecksum([]byte(input), &expected)
+		stuffed.EncodeDelimiter(&expected)
+	}
+	assert.Equal(t, expected.Bytes(), encoded)
+
+	checkOptionsRoundTrip(t, inputs, stuffed.WithCodec(codec), stuffed.WithChecksum(), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 1 << 20}))
+}
+
+func TestOptionsChecksumMismatch(t *testing.T) {
+	var encoded bytes.Buffer
+	stuffed.EncodeWithChecksum([]byte("abc"), &encoded)
+	encoded.Bytes()[2] ^= 0x01
+	stuffed.EncodeDelimiter(&encoded)
+
+	s := stuffed.NewScanner(encoded.Bytes(), stuffed.WithChecksum())
+	require.True(t, s.Next())
+	decoded := bytes.NewBufferString("prefix")
+	assert.Equal(t, stuffed.ErrChecksumMismatch, s.Decode(decoded))
+	assert.Equal(t, "prefix", decoded.String())
+
+	rb := stuffed.NewRecordBuilder(stuffed.WithChecksum())
+	assert.Equal(t, stuffed.ErrChecksumMismatch, rb.AddEncoded(encoded.Bytes()[:encoded.Len()-2]))
+}
+
+func TestOptionsLimits(t *testing.T) {
+	encoded := encodeList([]string{"abc", "abcdef"})
+	limits := stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 4})
+
+	s := stuffed.NewScanner(encoded, limits)
+	var decoded bytes.Buffer
+	require.True(t, s.Next())
+	require.NoError(t, s.Decode(&decoded))
+	require.True(t, s.Next())
+	err := s.Decode(&decoded)
+	require.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+	assert.Equal(t, 4, err.(*stuffed.ErrRecordTooLarge).Limit)
+
+	// A checksum doesn't count against the record size.
+	rb := stuffed.NewRecordBuilder(stuffed.WithChecksum())
+	rb.WriteString("abcd")
+	rb.FinishRecord()
+	var withChecksum bytes.Buffer
+	rb.Encode(&withChecksum)
+	s = stuffed.NewScanner(withChecksum.Bytes(), limits, stuffed.WithChecksum())
+	decoded.Reset()
+	require.True(t, s.Next())
+	require.NoError(t, s.Decode(&decoded))
+	assert.Equal(t, "abcd", decoded.String())
+}
+
+func TestOptionsOneByteDelimiterAtLimit(t *testing.T) {
+	codec, err := stuffed.NewCodec([]byte{0x00})
+	require.NoError(t, err)
+	assert.Equal(t, 201, codec.MaxEncodedLen(100))
+
+	// Every byte of these records is a delimiter, which is the worst case for
+	// a one-byte delimiter.
+	inputs := []string{string(make([]byte, 100)), "", string(make([]byte, 100))}
+	encoded := checkOptionsRoundTrip(t, inputs, stuffed.WithCodec(codec), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 100}))
+	assert.Equal(t, 2*201+1+3, len(encoded))
+}
+
+func TestOptionsLenient(t *testing.T) {
+	var encoded bytes.Buffer
+	for _, input := range []string{"abc", "def", "ghi"} {
+		stuffed.EncodeWithChecksum([]byte(input), &encoded)
+		stuffed.EncodeDelimiter(&encoded)
+	}
+	// Corrupt the content of the second record, leaving its run headers
+	// intact.
+	encoded.Bytes()[12] ^= 0x01
+
+	s := stuffed.NewScanner(encoded.Bytes(), stuffed.WithChecksum())
+	s.SetLenient(true)
+	actual := []string{}
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, []string{"abc", "ghi"}, actual)
+	assert.Equal(t, 8, s.Skipped())
+	assert.Equal(t, stuffed.ErrChecksumMismatch, s.LastError())
+
+	reader := stuffed.NewReader(bytes.NewReader(encoded.Bytes()), stuffed.WithChecksum())
+	reader.SetLenient(true)
+	actual = []string{}
+	for reader.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, reader.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	require.NoError(t, reader.Err())
+	assert.Equal(t, []string{"abc", "ghi"}, actual)
+	assert.Equal(t, 8, reader.Skipped())
+	assert.Equal(t, stuffed.ErrChecksumMismatch, reader.LastError())
+}
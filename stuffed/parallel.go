@@ -0,0 +1,91 @@
+package stuffed
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ParallelPrefixScan runs FindRecordsWithPrefix concurrently over several
+// buffers ("shards"), each of which must contain a list of stuffed records
+// sorted by their decoded content.  It returns the encoded content of every
+// matching record, merged across all of the shards into sorted order.  (Records
+// with equal content are returned in shard order.)  We use at most workers
+// goroutines; if workers is not positive, we use GOMAXPROCS.
+func ParallelPrefixScan(shards [][]byte, prefix []byte, workers int) ([][]byte, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	matches := make([][]byte, len(shards))
+	errs := make([]error, len(shards))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				matches[i], errs[i] = FindRecordsWithPrefix(shards[i], prefix)
+			}
+		}()
+	}
+	for i := range shards {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mergeSortedLists(matches, CompareEncoded)
+}
+
+// mergeShard is the state of one input list during a k-way merge.
+type mergeShard struct {
+	scanner Scanner
+	done    bool
+}
+
+func (m *mergeShard) advance() {
+	m.done = !m.scanner.Next()
+}
+
+// mergeSortedLists performs a k-way merge of several sorted lists of stuffed
+// records, returning the encoded content of each record in sorted order.  We
+// use compare to compare the records' encoded content.
+func mergeSortedLists(lists [][]byte, compare func(a, b []byte) (int, error)) ([][]byte, error) {
+	shards := make([]mergeShard, len(lists))
+	for i := range shards {
+		shards[i].scanner.Reset(lists[i])
+		shards[i].advance()
+	}
+
+	var result [][]byte
+	for {
+		min := -1
+		for i := range shards {
+			if shards[i].done {
+				continue
+			}
+			if min == -1 {
+				min = i
+				continue
+			}
+			cmp, err := compare(shards[i].scanner.Encoded(), shards[min].scanner.Encoded())
+			if err != nil {
+				return nil, err
+			}
+			if cmp < 0 {
+				min = i
+			}
+		}
+		if min == -1 {
+			return result, nil
+		}
+		result = append(result, shards[min].scanner.Encoded())
+		shards[min].advance()
+	}
+}
@@ -0,0 +1,52 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkParallelPrefixScan(t require.TestingT, shardLists [][]string, prefix string, workers int) {
+	var shards [][]byte
+	var expected []string
+	for _, inputList := range shardLists {
+		sorted := append([]string{}, inputList...)
+		sort.Strings(sorted)
+		shards = append(shards, encodeList(sorted))
+		for _, input := range sorted {
+			if strings.HasPrefix(input, prefix) {
+				expected = append(expected, input)
+			}
+		}
+	}
+	sort.Strings(expected)
+
+	matches, err := stuffed.ParallelPrefixScan(shards, []byte(prefix), workers)
+	require.NoError(t, err)
+	var actual []string
+	for _, match := range matches {
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.Decode(match, &decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestParallelPrefixScan(t *testing.T) {
+	shardLists := [][]string{
+		{"abc", "abd", "b"},
+		{},
+		{"ab", "abc\xfe\xfd", "c"},
+		shortTestCaseInputs(),
+	}
+	for _, tc := range prefixTestCases {
+		for _, workers := range []int{0, 1, 3} {
+			checkParallelPrefixScan(t, shardLists, tc.prefix, workers)
+		}
+	}
+}
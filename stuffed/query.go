@@ -0,0 +1,165 @@
+package stuffed
+
+import (
+	"bytes"
+	"sort"
+)
+
+// KeyRange restricts a query to records whose decoded content is in the range
+// [Start, End).  A nil Start or End means that that side of the range is
+// unbounded.
+type KeyRange struct {
+	Start, End []byte
+}
+
+// Query combines several ways of filtering a sorted list of stuffed records.
+// Its Execute methods pick the cheapest way to apply each filter, so that you
+// don't have to combine FindRecordsWithPrefix and friends by hand.  All of the
+// fields are optional; the zero Query matches every record.  Executing a Query
+// doesn't modify it, so you can execute the same Query from several goroutines
+// at once, as long as its Predicate is safe to call concurrently.
+type Query struct {
+	// Prefix restricts the query to records whose decoded content starts with
+	// this prefix.
+	Prefix []byte
+	// Range restricts the query to records whose decoded content falls within
+	// this range.
+	Range KeyRange
+	// Predicate, if not nil, is called with the decoded content of each
+	// candidate record, and restricts the query to records for which it
+	// returns true.  The decoded content is only valid during the call.
+	Predicate func(decoded []byte) bool
+	// Limit, if positive, is the maximum number of records to return.
+	Limit int
+	// Desc returns records in descending order instead of ascending order.
+	Desc bool
+}
+
+// Execute runs a query against a buffer containing a list of stuffed records
+// sorted by their decoded content, returning the encoded content of each
+// matching record.  We binary search for the records matching both Prefix and
+// Range, and then apply the remaining filters to each of those records in
+// turn.
+func (q *Query) Execute(encodedList []byte) ([][]byte, error) {
+	candidates := encodedList
+	if len(q.Prefix) > 0 {
+		var err error
+		candidates, err = FindRecordsWithPrefix(encodedList, q.Prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	scratch := getScratch()
+	defer putScratch(scratch)
+	key := &scratch.a
+	if q.Range.Start != nil {
+		key.Reset()
+		Encode(q.Range.Start, key)
+		first, err := searchRecords(candidates, func(record []byte) (bool, error) {
+			cmp, err := CompareEncoded(record, key.Bytes())
+			return cmp >= 0, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates = candidates[first:]
+	}
+	if q.Range.End != nil {
+		key.Reset()
+		Encode(q.Range.End, key)
+		last, err := searchRecords(candidates, func(record []byte) (bool, error) {
+			cmp, err := CompareEncoded(record, key.Bytes())
+			return cmp >= 0, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		candidates = candidates[:last]
+	}
+
+	var records [][]byte
+	var s Scanner
+	s.Reset(candidates)
+	for s.Next() {
+		records = append(records, s.Encoded())
+	}
+	return q.filter(nil, records)
+}
+
+// ExecuteWithInfo runs a query against the sorted list of stuffed records
+// described by a ListInfo.  We use the ListInfo's record table to binary search
+// for the records matching both Prefix and Range, and then apply the remaining
+// filters to each of those records in turn.
+func (q *Query) ExecuteWithInfo(info *ListInfo) ([][]byte, error) {
+	first, last, err := info.prefixBounds(q.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var searchErr error
+	compare := func(i int, key []byte) int {
+		decoded.Reset()
+		if err := info.codec.Decode(info.Record(i), decoded); err != nil && searchErr == nil {
+			searchErr = err
+		}
+		return bytes.Compare(decoded.Bytes(), key)
+	}
+	if q.Range.Start != nil {
+		first += sort.Search(last-first, func(i int) bool {
+			return compare(first+i, q.Range.Start) >= 0
+		})
+	}
+	if q.Range.End != nil && first < last {
+		last = first + sort.Search(last-first, func(i int) bool {
+			return compare(first+i, q.Range.End) >= 0
+		})
+	}
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	var records [][]byte
+	for i := first; i < last; i++ {
+		records = append(records, info.Record(i))
+	}
+	return q.filter(info.codec, records)
+}
+
+// filter applies the Predicate, Limit, and Desc filters to a sorted list of
+// candidate records.  Both Execute methods have already used binary search to
+// restrict the candidates to the records matching Prefix and Range.  The
+// records were encoded with codec.
+func (q *Query) filter(codec *Codec, records [][]byte) ([][]byte, error) {
+	var result [][]byte
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	for i := range records {
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+
+		record := records[i]
+		if q.Desc {
+			record = records[len(records)-1-i]
+		}
+		if q.Predicate == nil {
+			result = append(result, record)
+			continue
+		}
+
+		decoded.Reset()
+		if err := codec.Decode(record, decoded); err != nil {
+			return nil, err
+		}
+		if !q.Predicate(decoded.Bytes()) {
+			continue
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
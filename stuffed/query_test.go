@@ -0,0 +1,123 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAll(t require.TestingT, records [][]byte) []string {
+	actual := []string{}
+	for _, record := range records {
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.Decode(record, &decoded))
+		actual = append(actual, decoded.String())
+	}
+	return actual
+}
+
+func checkQuery(t require.TestingT, inputList []string, q stuffed.Query) {
+	sort.Strings(inputList)
+	expected := []string{}
+	for _, input := range inputList {
+		if !strings.HasPrefix(input, string(q.Prefix)) {
+			continue
+		}
+		if q.Range.Start != nil && input < string(q.Range.Start) {
+			continue
+		}
+		if q.Range.End != nil && input >= string(q.Range.End) {
+			continue
+		}
+		if q.Predicate != nil && !q.Predicate([]byte(input)) {
+			continue
+		}
+		expected = append(expected, input)
+	}
+	if q.Desc {
+		for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+			expected[i], expected[j] = expected[j], expected[i]
+		}
+	}
+	if q.Limit > 0 && len(expected) > q.Limit {
+		expected = expected[:q.Limit]
+	}
+
+	encoded := encodeList(inputList)
+	records, err := q.Execute(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, expected, decodeAll(t, records))
+
+	info, err := stuffed.NewListInfo(encoded)
+	require.NoError(t, err)
+	records, err = q.ExecuteWithInfo(info)
+	require.NoError(t, err)
+	assert.Equal(t, expected, decodeAll(t, records))
+}
+
+func TestQuery(t *testing.T) {
+	inputList := []string{"a", "ab", "abc", "abd", "abe", "b", "bc", "c"}
+	shortRecords := func(decoded []byte) bool { return len(decoded) < 3 }
+	queries := []stuffed.Query{
+		{},
+		{Prefix: []byte("ab")},
+		{Prefix: []byte("ab"), Limit: 2},
+		{Prefix: []byte("ab"), Limit: 2, Desc: true},
+		{Range: stuffed.KeyRange{Start: []byte("abd"), End: []byte("bc")}},
+		{Range: stuffed.KeyRange{Start: []byte("abd")}},
+		{Range: stuffed.KeyRange{End: []byte("abd")}, Desc: true},
+		{Prefix: []byte("ab"), Range: stuffed.KeyRange{Start: []byte("abd")}},
+		{Predicate: shortRecords},
+		{Prefix: []byte("a"), Predicate: shortRecords, Desc: true, Limit: 1},
+		{Prefix: []byte("z")},
+	}
+	for _, q := range queries {
+		checkQuery(t, inputList, q)
+	}
+}
+
+func TestQueryConcurrent(t *testing.T) {
+	inputList := []string{"a", "ab", "abc", "abd", "abe", "b", "bc", "c"}
+	info, err := stuffed.NewListInfo(encodeList(inputList))
+	require.NoError(t, err)
+	q := stuffed.Query{
+		Range:     stuffed.KeyRange{Start: []byte("ab"), End: []byte("bc")},
+		Predicate: func(decoded []byte) bool { return len(decoded) > 1 },
+	}
+	expected := []string{"ab", "abc", "abd", "abe"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				records, err := q.ExecuteWithInfo(info)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, decodeAll(t, records))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestQueryRangeSkipsOutOfRangeRecords(t *testing.T) {
+	// The records after the range are malformed after their first byte.  We
+	// only need that first byte to binary search past them, so a Range-only
+	// query never decodes them.
+	encoded := encodeList([]string{"a", "b"})
+	for i := 0; i < 16; i++ {
+		encoded = append(encoded, "\x01z\x05"...)
+		encoded = append(encoded, delimiter...)
+	}
+	q := stuffed.Query{Range: stuffed.KeyRange{Start: []byte("b"), End: []byte("c")}}
+	records, err := q.Execute(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, decodeAll(t, records))
+}
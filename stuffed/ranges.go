@@ -0,0 +1,117 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// InvalidRange is the error that is returned when you ask for a range of
+	// decoded content that is malformed or extends past the end of a record.
+	InvalidRange = errors.New("Invalid decoded range")
+)
+
+// DecodeRange decodes the bytes in the range [from, to) of a stuffed record's
+// decoded content into an output buffer.  We only look at the run headers
+// until we reach from, and stop as soon as we reach to, so this is much
+// cheaper than decoding the entire record when you only need a small piece of
+// it.
+func DecodeRange(encoded []byte, from, to int, dst *bytes.Buffer) error {
+	if from < 0 || from > to {
+		return InvalidRange
+	}
+
+	// pos is the decoded offset of the start of the current chunk.  We walk
+	// the chunks until we reach to even if the range is empty, so that an
+	// empty range past the end of the record is still an error.
+	pos := 0
+	r := newChunkReader(encoded)
+	for pos < to {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return InvalidRange
+		}
+
+		chunkEnd := pos + len(chunk)
+		if chunkEnd > from {
+			start := from - pos
+			if start < 0 {
+				start = 0
+			}
+			end := len(chunk)
+			if chunkEnd > to {
+				end = to - pos
+			}
+			dst.Write(chunk[start:end])
+		}
+		pos = chunkEnd
+	}
+	return nil
+}
+
+// Range identifies the bytes in the range [From, To) of a stuffed record's
+// decoded content.
+type Range struct {
+	From, To int
+}
+
+// ProjectFields extracts several ranges of a stuffed record's decoded content
+// in a single pass over its run headers.  This is useful when records have a
+// fixed layout and you only need a few fields from each one.  The result
+// contains one newly allocated slice for each range, in the same order as
+// ranges.  Ranges can overlap and do not need to be sorted.
+func ProjectFields(encoded []byte, ranges []Range) ([][]byte, error) {
+	end := 0
+	fields := make([][]byte, len(ranges))
+	for i, rng := range ranges {
+		if rng.From < 0 || rng.From > rng.To {
+			return nil, InvalidRange
+		}
+		if rng.To > end {
+			end = rng.To
+		}
+		// A record's decoded content is never longer than its encoded
+		// content, so we don't trust a range to be any larger than that.
+		capacity := rng.To - rng.From
+		if capacity > len(encoded) {
+			capacity = len(encoded)
+		}
+		fields[i] = make([]byte, 0, capacity)
+	}
+
+	// pos is the decoded offset of the start of the current chunk.  As in
+	// DecodeRange, we walk the chunks until we reach the end of every range,
+	// including empty ones.
+	pos := 0
+	r := newChunkReader(encoded)
+	for pos < end {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, InvalidRange
+		}
+
+		chunkEnd := pos + len(chunk)
+		for i, rng := range ranges {
+			if chunkEnd <= rng.From || pos >= rng.To {
+				continue
+			}
+			start := rng.From - pos
+			if start < 0 {
+				start = 0
+			}
+			end := len(chunk)
+			if chunkEnd > rng.To {
+				end = rng.To - pos
+			}
+			fields[i] = append(fields[i], chunk[start:end]...)
+		}
+		pos = chunkEnd
+	}
+	return fields, nil
+}
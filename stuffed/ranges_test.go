@@ -0,0 +1,75 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkDecodeRange(t require.TestingT, decoded string, from, to int) {
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte(decoded), &encoded)
+	var actual bytes.Buffer
+	err := stuffed.DecodeRange(encoded.Bytes(), from, to, &actual)
+	if from < 0 || from > to || to > len(decoded) {
+		assert.Equal(t, stuffed.InvalidRange, err)
+		return
+	}
+	require.NoError(t, err)
+	assert.Equal(t, decoded[from:to], actual.String())
+}
+
+func TestDecodeRange(t *testing.T) {
+	for _, tc := range shortTestCases {
+		length := len(tc.decoded)
+		ranges := [][2]int{
+			{0, 0},
+			{0, length},
+			{0, length / 2},
+			{length / 2, length},
+			{length / 3, 2 * length / 3},
+			{length, length},
+			{0, length + 1},
+			{length + 1, length + 1},
+			{1000, 1000},
+			{-1, 0},
+			{1, 0},
+		}
+		for _, r := range ranges {
+			checkDecodeRange(t, tc.decoded, r[0], r[1])
+		}
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	decoded := "abc\xfe\xfd" + string256 + "\xfe\xfdxyz"
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte(decoded), &encoded)
+
+	ranges := []stuffed.Range{
+		{0, 3},
+		{200, 262},
+		{2, 6},
+		{5, 5},
+		{len(decoded) - 4, len(decoded)},
+	}
+	fields, err := stuffed.ProjectFields(encoded.Bytes(), ranges)
+	require.NoError(t, err)
+	require.Len(t, fields, len(ranges))
+	for i, rng := range ranges {
+		assert.Equal(t, decoded[rng.From:rng.To], string(fields[i]))
+	}
+
+	_, err = stuffed.ProjectFields(encoded.Bytes(), []stuffed.Range{{0, len(decoded) + 1}})
+	assert.Equal(t, stuffed.InvalidRange, err)
+	_, err = stuffed.ProjectFields(encoded.Bytes(), []stuffed.Range{{0, 3}, {1000, 1000}})
+	assert.Equal(t, stuffed.InvalidRange, err)
+	_, err = stuffed.ProjectFields(encoded.Bytes(), []stuffed.Range{{0, math.MaxInt64}})
+	assert.Equal(t, stuffed.InvalidRange, err)
+	_, err = stuffed.ProjectFields(encoded.Bytes(), []stuffed.Range{{3, 2}})
+	assert.Equal(t, stuffed.InvalidRange, err)
+}
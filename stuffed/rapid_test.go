@@ -3,6 +3,7 @@ package stuffed_test
 import (
 	"bytes"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -102,6 +103,19 @@ func TestFindRecordsWithPrefixRandomLists(t *testing.T) {
 	})
 }
 
+func TestFindRecordsWithPrefixCheckedRandomLists(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		inputList, prefix, _ := prefixLists(t)
+		sort.Strings(inputList)
+		encoded := encodeList(inputList)
+		expected, err := stuffed.FindRecordsWithPrefix(encoded, []byte(prefix))
+		require.NoError(t, err)
+		actual, err := stuffed.FindRecordsWithPrefixChecked(encoded, []byte(prefix))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+}
+
 func TestRecordBuilderWithRandomLists(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		inputList := rapid.SliceOf(inputString).Draw(t, "inputList").([]string)
@@ -115,3 +129,64 @@ func TestSortedRecordBuilderWithRandomLists(t *testing.T) {
 		checkSortedRecordBuilder(t, inputList)
 	})
 }
+
+func TestDecodeRangeWithRandomInputs(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		input := inputString.Draw(t, "input").(string)
+		to := rapid.IntRange(0, len(input)).Draw(t, "to").(int)
+		from := rapid.IntRange(0, to).Draw(t, "from").(int)
+		checkDecodeRange(t, input, from, to)
+	})
+}
+
+func TestListInfoWithRandomLists(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		inputList, prefix, _ := prefixLists(t)
+		checkListInfo(t, inputList, prefix)
+	})
+}
+
+func TestAppendRoundTrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		input := inputString.Draw(t, "input").(string)
+		var expected bytes.Buffer
+		stuffed.Encode([]byte(input), &expected)
+		encoded := stuffed.EncodeAppend(nil, []byte(input))
+		assert.Equal(t, expected.Bytes(), encoded)
+		decoded, err := stuffed.DecodeAppend(nil, encoded)
+		require.NoError(t, err)
+		assert.Equal(t, input, string(decoded))
+	})
+}
+
+func TestCompareEncodedWithRandomInputs(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		common := inputString.Draw(t, "common").(string)
+		a := common + inputString.Draw(t, "a").(string)
+		b := common + inputString.Draw(t, "b").(string)
+		var encodedA, encodedB bytes.Buffer
+		stuffed.Encode([]byte(a), &encodedA)
+		stuffed.Encode([]byte(b), &encodedB)
+		cmp, err := stuffed.CompareEncoded(encodedA.Bytes(), encodedB.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, strings.Compare(a, b), cmp)
+	})
+}
+
+func TestCodecRoundTripRandomLists(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		delim := rapid.SampledFrom(codecDelimiters).Draw(t, "delim").(string)
+		codec, err := stuffed.NewCodec([]byte(delim))
+		require.NoError(t, err)
+		chunk := rapid.OneOf(rapid.String(), rapid.Just(delim))
+		input := rapid.Custom(func(t *rapid.T) string {
+			var buf strings.Builder
+			for _, chunk := range rapid.SliceOf(chunk).Draw(t, "chunks").([]interface{}) {
+				buf.WriteString(chunk.(string))
+			}
+			return buf.String()
+		})
+		inputList := rapid.SliceOf(input).Draw(t, "inputList").([]string)
+		checkCodecRoundTrip(t, codec, inputList)
+	})
+}
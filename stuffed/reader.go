@@ -0,0 +1,106 @@
+package stuffed
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Reader reads delimited stuffed records from an io.Reader, one record at a
+// time.  Unlike Scanner, a Reader doesn't need the entire encoded list to be
+// in memory; it only buffers enough of the stream to hold the current record.
+// It handles delimiters that span read boundaries.  Like the io.Reader that it
+// wraps, a Reader must only be used by one goroutine at a time.
+type Reader struct {
+	scanner *bufio.Scanner
+	record  []byte
+	opts    options
+	lenient bool
+	skipped int
+	lastErr error
+}
+
+// NewReader creates a new Reader that reads delimited stuffed records from r,
+// configured with some options.  We refuse to buffer any record whose encoded
+// size is larger than that of a record of DefaultMaxRecordSize bytes (or of the
+// MaxRecordSize of any Limits that you provide).
+func NewReader(r io.Reader, opts ...Option) *Reader {
+	o := newOptions(opts)
+	maxRecordSize := DefaultMaxRecordSize
+	if o.limits != nil && o.limits.MaxRecordSize > 0 {
+		maxRecordSize = o.limits.MaxRecordSize
+	}
+	// The scanner can't return a record until it sees the delimiter after it,
+	// and it might also be holding on to the delimiter before it, so we need
+	// room for both of those in addition to the record itself.
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, o.maxEncodedLen(maxRecordSize)+2*len(o.codec.delim()))
+	scanner.Split(o.codec.SplitRecords)
+	return &Reader{scanner: scanner, opts: o}
+}
+
+// Next returns whether there is a next stuffed record in the stream.  If this
+// returns true, you can use Encoded and Decode to access that record.  If it
+// returns false, you should check Err to see whether we reached the end of the
+// stream or encountered an error.
+func (r *Reader) Next() bool {
+	for r.scanner.Scan() {
+		r.record = r.scanner.Bytes()
+		if !r.lenient {
+			return true
+		}
+		if err := r.opts.validate(r.record); err != nil {
+			r.skipped += len(r.record)
+			r.lastErr = err
+			continue
+		}
+		return true
+	}
+	r.record = nil
+	return false
+}
+
+// SetLenient controls whether a Reader tolerates corrupt records, just like
+// Scanner.SetLenient.  In lenient mode, Next skips over any malformed record,
+// and resynchronizes at the next delimiter.
+//
+// Because the encoding never produces the delimiter sequence, any occurrence of
+// it in the stream is treated as a record boundary, even if it appears where
+// we'd expect a run header or run content of a damaged record.  Corruption
+// within one record can therefore never affect the records around it.  (A
+// damaged record might still decode successfully, though, possibly as several
+// records, if the corruption introduces a delimiter.  Use checksums if you
+// need to detect that.)
+func (r *Reader) SetLenient(lenient bool) {
+	r.lenient = lenient
+}
+
+// Skipped returns the number of bytes of malformed records that a lenient
+// Reader has skipped over.
+func (r *Reader) Skipped() int {
+	return r.skipped
+}
+
+// LastError returns the error that caused a lenient Reader to most recently
+// skip over a malformed record, or nil if it hasn't skipped any.
+func (r *Reader) LastError() error {
+	return r.lastErr
+}
+
+// Err returns the first error that we encountered while reading from the
+// underlying stream.  It returns nil if we reached the end of the stream
+// successfully.
+func (r *Reader) Err() error {
+	return r.scanner.Err()
+}
+
+// Encoded returns the encoded content of the current stuffed record.  The
+// result is only valid until the next call to Next.
+func (r *Reader) Encoded() []byte {
+	return r.record
+}
+
+// Decode reads the current stuffed record and decodes it into an output Buffer.
+func (r *Reader) Decode(decoded *bytes.Buffer) error {
+	return r.opts.decode(r.record, decoded)
+}
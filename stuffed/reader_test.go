@@ -0,0 +1,132 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readStrings(t require.TestingT, r io.Reader) []string {
+	reader := stuffed.NewReader(r)
+	actual := []string{}
+	for reader.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, reader.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	require.NoError(t, reader.Err())
+	return actual
+}
+
+func TestReader(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	inputList = append(inputList, strings.Repeat("a\xfe\xfd", 100000))
+	encoded := encodeList(inputList)
+
+	assert.Equal(t, inputList, readStrings(t, bytes.NewReader(encoded)))
+	assert.Equal(t, inputList, readStrings(t, iotest.OneByteReader(bytes.NewReader(encoded))))
+	assert.Equal(t, inputList, readStrings(t, iotest.DataErrReader(bytes.NewReader(encoded))))
+	assert.Equal(t, []string{}, readStrings(t, bytes.NewReader(nil)))
+}
+
+func TestReaderAtLimit(t *testing.T) {
+	limits := stuffed.Limits{MaxRecordSize: 1000}
+	inputList := []string{strings.Repeat("a", 1000), "b", strings.Repeat("c", 1000)}
+	encoded := append([]byte("\xfe\xfd\xfe\xfd"), encodeList(inputList)...)
+
+	for _, r := range []io.Reader{
+		bytes.NewReader(encoded),
+		iotest.OneByteReader(bytes.NewReader(encoded)),
+		iotest.HalfReader(bytes.NewReader(encoded)),
+	} {
+		reader := stuffed.NewReader(r, stuffed.WithLimits(limits))
+		actual := []string{}
+		for reader.Next() {
+			var decoded bytes.Buffer
+			require.NoError(t, reader.Decode(&decoded))
+			actual = append(actual, decoded.String())
+		}
+		require.NoError(t, reader.Err())
+		assert.Equal(t, inputList, actual)
+	}
+
+	// A record that's too large still fails.
+	reader := stuffed.NewReader(bytes.NewReader(encodeList([]string{strings.Repeat("a", 1010)})), stuffed.WithLimits(limits))
+	assert.False(t, reader.Next())
+	assert.Error(t, reader.Err())
+}
+
+func TestReaderError(t *testing.T) {
+	encoded := encodeList([]string{"abc", "def"})
+	reader := stuffed.NewReader(iotest.TimeoutReader(bytes.NewReader(encoded)))
+	for reader.Next() {
+	}
+	assert.Equal(t, iotest.ErrTimeout, reader.Err())
+}
+
+func readStringsLenient(t require.TestingT, r io.Reader) ([]string, *stuffed.Reader) {
+	reader := stuffed.NewReader(r)
+	reader.SetLenient(true)
+	actual := []string{}
+	for reader.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, reader.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	require.NoError(t, reader.Err())
+	return actual, reader
+}
+
+func TestLenientReader(t *testing.T) {
+	encoded := []byte("\x03abc\xfe\xfd\x05de\xfe\xfd\x03fgh\xfe\xfd\xff\xfe\xfd\x00")
+	actual, reader := readStringsLenient(t, iotest.OneByteReader(bytes.NewReader(encoded)))
+	assert.Equal(t, []string{"abc", "fgh", ""}, actual)
+	assert.Equal(t, 4, reader.Skipped())
+	assert.Equal(t, stuffed.InvalidRunLength, reader.LastError())
+}
+
+// TestReaderResync corrupts every byte of a record in turn, and checks that
+// the records on either side of it always survive intact.
+func TestReaderResync(t *testing.T) {
+	before := "before\xfe\xfd"
+	after := "after"
+	for _, damaged := range []string{"", "abc", "a\xfe\xfdb", strings.Repeat("x", 300) + "\xfe\xfd" + "y"} {
+		var encodedDamaged bytes.Buffer
+		stuffed.Encode([]byte(damaged), &encodedDamaged)
+		prefix := encodeList([]string{before})
+		suffix := encodeList([]string{after})
+
+		for i := 0; i < encodedDamaged.Len(); i++ {
+			corruptions := [][]byte{
+				{0x00}, {0xff}, {0xfe}, {0xfd}, {0xfc},
+				// A delimiter where we expect a run header or content.
+				{0xfe, 0xfd},
+				// The rest of the record is missing (a torn write).
+				{},
+			}
+			for _, corruption := range corruptions {
+				var stream bytes.Buffer
+				stream.Write(prefix)
+				stream.Write(encodedDamaged.Bytes()[:i])
+				stream.Write(corruption)
+				// An empty corruption truncates the rest of the record.
+				if end := i + len(corruption); len(corruption) > 0 && end < encodedDamaged.Len() {
+					stream.Write(encodedDamaged.Bytes()[end:])
+				}
+				stream.Write([]byte{0xfe, 0xfd})
+				stream.Write(suffix)
+
+				actual, _ := readStringsLenient(t, bytes.NewReader(stream.Bytes()))
+				require.True(t, len(actual) >= 2, "damaged %q at %d with %q", damaged, i, corruption)
+				assert.Equal(t, before, actual[0], "damaged %q at %d with %q", damaged, i, corruption)
+				assert.Equal(t, after, actual[len(actual)-1], "damaged %q at %d with %q", damaged, i, corruption)
+			}
+		}
+	}
+}
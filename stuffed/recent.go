@@ -0,0 +1,81 @@
+package stuffed
+
+import (
+	"sync"
+)
+
+// RecentBuffer retains the most recent n records that are added to it, which
+// is useful for building "last n events" views over a stream of stuffed
+// records.  Adding a record takes constant time (other than copying the record
+// itself), and evicts the oldest record once the buffer is full.  A
+// RecentBuffer is safe for concurrent use.
+type RecentBuffer struct {
+	mu      sync.Mutex
+	records [][]byte
+	next    int
+	full    bool
+}
+
+// NewRecentBuffer creates a new RecentBuffer that retains up to n records.  A
+// buffer with n == 0 retains nothing.  We panic if n is negative.
+func NewRecentBuffer(n int) *RecentBuffer {
+	if n < 0 {
+		panic("stuffed: NewRecentBuffer called with negative size")
+	}
+	return &RecentBuffer{records: make([][]byte, n)}
+}
+
+// Add adds a copy of a decoded record to the buffer.
+func (b *RecentBuffer) Add(record []byte) {
+	copied := make([]byte, len(record))
+	copy(copied, record)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.records) == 0 {
+		return
+	}
+	b.records[b.next] = copied
+	b.next++
+	if b.next == len(b.records) {
+		b.next = 0
+		b.full = true
+	}
+}
+
+// AddEncoded decodes a stuffed record and adds its decoded content to the
+// buffer.
+func (b *RecentBuffer) AddEncoded(encoded []byte) error {
+	scratch := getScratch()
+	defer putScratch(scratch)
+	if err := Decode(encoded, &scratch.a); err != nil {
+		return err
+	}
+	// Add copies the record, so we can reuse the scratch buffer.
+	b.Add(scratch.a.Bytes())
+	return nil
+}
+
+// Len returns the number of records currently in the buffer.
+func (b *RecentBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.full {
+		return len(b.records)
+	}
+	return b.next
+}
+
+// Snapshot returns the records currently in the buffer, from oldest to newest.
+// The records are shared with the buffer, and must not be modified, but they
+// remain valid even after they're evicted.
+func (b *RecentBuffer) Snapshot() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([][]byte(nil), b.records[:b.next]...)
+	}
+	result := make([][]byte, 0, len(b.records))
+	result = append(result, b.records[b.next:]...)
+	return append(result, b.records[:b.next]...)
+}
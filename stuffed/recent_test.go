@@ -0,0 +1,50 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func snapshotStrings(b *stuffed.RecentBuffer) []string {
+	result := []string{}
+	for _, record := range b.Snapshot() {
+		result = append(result, string(record))
+	}
+	return result
+}
+
+func TestRecentBuffer(t *testing.T) {
+	b := stuffed.NewRecentBuffer(3)
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, []string{}, snapshotStrings(b))
+
+	var expected []string
+	for i := 0; i < 7; i++ {
+		record := fmt.Sprintf("record %d", i)
+		b.Add([]byte(record))
+		expected = append(expected, record)
+		if len(expected) > 3 {
+			expected = expected[1:]
+		}
+		assert.Equal(t, len(expected), b.Len())
+		assert.Equal(t, expected, snapshotStrings(b))
+	}
+
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte("abc\xfe\xfd"), &encoded)
+	require.NoError(t, b.AddEncoded(encoded.Bytes()))
+	assert.Equal(t, []string{"record 5", "record 6", "abc\xfe\xfd"}, snapshotStrings(b))
+
+	empty := stuffed.NewRecentBuffer(0)
+	empty.Add([]byte("abc"))
+	assert.Equal(t, 0, empty.Len())
+
+	assert.PanicsWithValue(t, "stuffed: NewRecentBuffer called with negative size", func() {
+		stuffed.NewRecentBuffer(-1)
+	})
+}
@@ -2,23 +2,41 @@ package stuffed
 
 import (
 	"bytes"
+	"errors"
 	"sort"
 )
 
+var (
+	// UnfinishedRecord is the error that is returned when you try to add an
+	// encoded record to a RecordBuilder while you're in the middle of building
+	// another record.
+	UnfinishedRecord = errors.New("Record is unfinished")
+)
+
 // RecordBuilder makes it easier to build up the content of individual records,
 // which are then written into a buffer using the stuffed records encoding.  To
 // build up the content of an individual record, just use the RecordBuilder as a
 // bytes.Buffer.  Once a record is done, call FinishRecord.  Once you are done
 // with all records, call Encode to get the encoded representation of
-// everything.
+// everything.  A RecordBuilder is not safe for concurrent use.
 type RecordBuilder struct {
 	bytes.Buffer
 	start         int
 	recordIndices []index
+	opts          options
+}
+
+// NewRecordBuilder returns a RecordBuilder that encodes records according to
+// some options.  (The zero RecordBuilder uses the default options.)
+func NewRecordBuilder(opts ...Option) *RecordBuilder {
+	return &RecordBuilder{opts: newOptions(opts)}
 }
 
 type index struct {
 	originalIndex, start, end int
+	// encoded is true if the record's content in the buffer has already been
+	// encoded.
+	encoded bool
 }
 
 // FinishRecord indicates that you have finished constructing an individual
@@ -27,19 +45,103 @@ type index struct {
 func (rb *RecordBuilder) FinishRecord() {
 	end := rb.Len()
 	originalIndex := len(rb.recordIndices)
-	rb.recordIndices = append(rb.recordIndices, index{originalIndex, rb.start, end})
+	rb.recordIndices = append(rb.recordIndices, index{originalIndex, rb.start, end, false})
+	rb.start = end
+}
+
+// AddEncoded adds a record that has already been encoded using the stuffed
+// records encoding, such as one that you've read from an existing list.  We
+// check that the record is well-formed, and then copy its encoded content
+// as-is, without decoding it and re-encoding it.  The record must have been
+// encoded with the same options as the builder.  (If the builder uses
+// checksums or a transform, we do have to decode the record to verify it.)  You
+// can't call this while you're in the middle of building a record.
+func (rb *RecordBuilder) AddEncoded(encoded []byte) error {
+	if rb.start != rb.Len() {
+		return UnfinishedRecord
+	}
+	if rb.opts.codec.FindDelimiter(encoded) != -1 {
+		return EmbeddedDelimiter
+	}
+	if err := rb.opts.validate(encoded); err != nil {
+		return err
+	}
+	rb.Write(encoded)
+	end := rb.Len()
+	originalIndex := len(rb.recordIndices)
+	rb.recordIndices = append(rb.recordIndices, index{originalIndex, rb.start, end, true})
 	rb.start = end
+	return nil
+}
+
+// DefaultEncoding returns whether this builder encodes records exactly like
+// the package-level Encode: with the default delimiter, and without checksums
+// or a transform.  Records from any other builder can only be read back using
+// the builder's options.
+func (rb *RecordBuilder) DefaultEncoding() bool {
+	return rb.opts.codec == nil && !rb.opts.checksum && rb.opts.transform == nil
+}
+
+// removeLastRecord removes the record that you most recently finished, even if
+// you've sorted the records since then.  Its content must still be at the end
+// of the buffer.
+func (rb *RecordBuilder) removeLastRecord() {
+	last := len(rb.recordIndices) - 1
+	for i, index := range rb.recordIndices {
+		if index.originalIndex == last {
+			rb.Buffer.Truncate(index.start)
+			rb.start = index.start
+			rb.recordIndices = append(rb.recordIndices[:i], rb.recordIndices[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reset removes all of the records from the builder, including any record that
+// you're in the middle of building, so that you can reuse it.  It keeps the
+// builder's options and any memory it has already allocated.  (Don't call the
+// embedded bytes.Buffer's Reset or Truncate methods directly, since those
+// don't know about the builder's records.)
+func (rb *RecordBuilder) Reset() {
+	rb.Buffer.Reset()
+	rb.start = 0
+	rb.recordIndices = rb.recordIndices[:0]
+}
+
+// maxEncodedLen returns the largest number of bytes that Encode can produce for
+// the records in this builder, so that we can grow the destination buffer once
+// up front instead of repeatedly while encoding.
+func (rb *RecordBuilder) maxEncodedLen() int {
+	result := 0
+	for _, index := range rb.recordIndices {
+		if index.encoded {
+			result += index.end - index.start + delimiterLength
+		} else {
+			result += rb.opts.maxEncodedLen(index.end-index.start) + delimiterLength
+		}
+	}
+	return result
 }
 
 // Encode encodes all of the records in this builder into an output buffer,
 // using the stuffed records encoding.
 func (rb *RecordBuilder) Encode(dest *bytes.Buffer) {
+	dest.Grow(rb.maxEncodedLen())
 	records := rb.Bytes()
 	for _, index := range rb.recordIndices {
-		record := records[index.start:index.end]
-		Encode(record, dest)
-		EncodeDelimiter(dest)
+		rb.encodeRecord(index, records, dest)
+	}
+}
+
+// encodeRecord writes a record, followed by a delimiter, to an output buffer.
+func (rb *RecordBuilder) encodeRecord(index index, records []byte, dest *bytes.Buffer) {
+	record := records[index.start:index.end]
+	if index.encoded {
+		dest.Write(record)
+	} else {
+		rb.opts.encode(record, dest)
 	}
+	rb.opts.codec.EncodeDelimiter(dest)
 }
 
 // EncodeWithOffsets encodes all of the records in this builder, just like
@@ -49,13 +151,12 @@ func (rb *RecordBuilder) Encode(dest *bytes.Buffer) {
 // indexes are based on the original order that you called FinishRecord, even if
 // you've sorted the records.
 func (rb *RecordBuilder) EncodeWithOffsets(dest *bytes.Buffer) []int {
+	dest.Grow(rb.maxEncodedLen())
 	records := rb.Bytes()
 	recordOffsets := make([]int, len(rb.recordIndices))
 	for _, index := range rb.recordIndices {
 		recordOffsets[index.originalIndex] = dest.Len()
-		record := records[index.start:index.end]
-		Encode(record, dest)
-		EncodeDelimiter(dest)
+		rb.encodeRecord(index, records, dest)
 	}
 	return recordOffsets
 }
@@ -63,12 +164,91 @@ func (rb *RecordBuilder) EncodeWithOffsets(dest *bytes.Buffer) []int {
 // Sort sorts all of the records before encoding them, which allows you to use
 // FindRecordsWithPrefix on the encoded result.
 func (rb *RecordBuilder) Sort() {
-	sort.Sort(&recordSorter{rb.Bytes(), rb.recordIndices})
+	sort.Sort(&recordSorter{records: rb.Bytes(), recordIndices: rb.recordIndices, opts: &rb.opts})
+}
+
+// SortWithCollation sorts all of the records using a collation before encoding
+// them.  Use FindRecordsWithPrefixCollated and MergeSorted, with the same
+// collation, to search and merge the encoded result.
+func (rb *RecordBuilder) SortWithCollation(collation Collation) {
+	sort.Sort(&recordSorter{records: rb.Bytes(), recordIndices: rb.recordIndices, opts: &rb.opts, collation: collation})
+}
+
+// SortFunc sorts all of the records before encoding them, using a custom
+// ordering of their decoded content.  less must define a strict weak ordering.
+// To use FindRecordsWithPrefix on the encoded result, the records that begin
+// with any prefix you search for must be contiguous in this ordering, and
+// must sort in the same position relative to other records as they would
+// bytewise.  (For instance, you can sort "key\x00value" records by their keys.)
+func (rb *RecordBuilder) SortFunc(less func(a, b []byte) bool) {
+	sort.Sort(&recordSorter{records: rb.Bytes(), recordIndices: rb.recordIndices, opts: &rb.opts, less: less})
+}
+
+// SortAndDedup sorts all of the records, like Sort, and then removes any
+// records whose content is identical to another record's.  Returns the number
+// of records that were removed.  EncodeWithOffsets will number the remaining
+// records based on the order that you added them, skipping the ones that were
+// removed.
+func (rb *RecordBuilder) SortAndDedup() int {
+	rb.Sort()
+	s := &recordSorter{records: rb.Bytes(), recordIndices: rb.recordIndices, opts: &rb.opts}
+	kept := rb.recordIndices[:0]
+	isKept := make([]bool, len(rb.recordIndices))
+	for i, index := range rb.recordIndices {
+		if i > 0 && s.equal(kept[len(kept)-1], index) {
+			continue
+		}
+		kept = append(kept, index)
+		isKept[index.originalIndex] = true
+	}
+	removed := len(rb.recordIndices) - len(kept)
+	rb.recordIndices = kept
+	if removed == 0 {
+		return 0
+	}
+
+	// Renumber the remaining records so that their original indexes are
+	// contiguous again.
+	newIndexes := make([]int, len(isKept))
+	next := 0
+	for originalIndex, kept := range isKept {
+		if kept {
+			newIndexes[originalIndex] = next
+			next++
+		}
+	}
+	for i := range rb.recordIndices {
+		rb.recordIndices[i].originalIndex = newIndexes[rb.recordIndices[i].originalIndex]
+	}
+	return removed
 }
 
 type recordSorter struct {
 	records       []byte
 	recordIndices []index
+	opts          *options
+	collation     Collation
+	less          func(a, b []byte) bool
+	// Scratch space for decoding records that were added with AddEncoded.
+	decodedI, decodedJ bytes.Buffer
+}
+
+// decoded returns the decoded content of a record, using buf as scratch space
+// if the record was added in encoded form.
+func (s *recordSorter) decoded(index index, buf *bytes.Buffer) []byte {
+	record := s.records[index.start:index.end]
+	if !index.encoded {
+		return record
+	}
+	buf.Reset()
+	// AddEncoded has already checked that the record is well-formed.
+	s.opts.decode(record, buf)
+	return buf.Bytes()
+}
+
+// equal returns whether two records have the same decoded content.
+func (s *recordSorter) equal(a, b index) bool {
+	return bytes.Equal(s.decoded(a, &s.decodedI), s.decoded(b, &s.decodedJ))
 }
 
 func (s *recordSorter) Len() int {
@@ -76,10 +256,14 @@ func (s *recordSorter) Len() int {
 }
 
 func (s *recordSorter) Less(i, j int) bool {
-	indexI := s.recordIndices[i]
-	bytesI := s.records[indexI.start:indexI.end]
-	indexJ := s.recordIndices[j]
-	bytesJ := s.records[indexJ.start:indexJ.end]
+	bytesI := s.decoded(s.recordIndices[i], &s.decodedI)
+	bytesJ := s.decoded(s.recordIndices[j], &s.decodedJ)
+	if s.less != nil {
+		return s.less(bytesI, bytesJ)
+	}
+	if s.collation != nil {
+		return s.collation.Compare(bytesI, bytesJ) < 0
+	}
 	return bytes.Compare(bytesI, bytesJ) < 0
 }
 
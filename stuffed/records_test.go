@@ -2,6 +2,7 @@ package stuffed_test
 
 import (
 	"bytes"
+	"io"
 	"sort"
 	"testing"
 
@@ -135,3 +136,125 @@ func TestSortedRecordBuilder(t *testing.T) {
 		checkSortedRecordBuilderOffsets(t, testCases[i], offsets[i])
 	}
 }
+
+func TestRecordBuilderSortFunc(t *testing.T) {
+	key := func(record []byte) []byte {
+		return record[:bytes.IndexByte(record, 0x00)]
+	}
+	var builder stuffed.RecordBuilder
+	for _, record := range []string{"cherry\x00red", "apple\x00green", "banana\x00yellow", "apple\x00red"} {
+		builder.WriteString(record)
+		builder.FinishRecord()
+	}
+	require.NoError(t, builder.AddEncoded(bytes.TrimSuffix(encodeList([]string{"apricot\x00orange"}), delimiter)))
+	builder.SortFunc(func(a, b []byte) bool {
+		return bytes.Compare(key(a), key(b)) < 0
+	})
+	var encoded bytes.Buffer
+	builder.Encode(&encoded)
+
+	var keys []string
+	var scanner stuffed.Scanner
+	scanner.Reset(encoded.Bytes())
+	for scanner.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, scanner.Decode(&decoded))
+		keys = append(keys, string(key(decoded.Bytes())))
+	}
+	assert.Equal(t, []string{"apple", "apple", "apricot", "banana", "cherry"}, keys)
+
+	found, err := stuffed.FindRecordsWithPrefix(encoded.Bytes(), []byte("apple\x00"))
+	require.NoError(t, err)
+	actual, err := parseStrings(found)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"apple\x00green", "apple\x00red"}, actual)
+}
+
+func TestRecordBuilderAddEncoded(t *testing.T) {
+	// Alternate between adding records normally and in encoded form.
+	inputList := shortTestCaseInputs()
+	var builder stuffed.RecordBuilder
+	for i, tc := range shortTestCases {
+		if i%2 == 0 {
+			require.NoError(t, builder.AddEncoded([]byte(tc.encoded)))
+		} else {
+			builder.WriteString(tc.decoded)
+			builder.FinishRecord()
+		}
+	}
+	var encoded bytes.Buffer
+	builder.Encode(&encoded)
+	assert.Equal(t, encodeList(inputList), encoded.Bytes())
+
+	builder.Sort()
+	encoded.Reset()
+	builder.Encode(&encoded)
+	sorted := append([]string{}, inputList...)
+	sort.Strings(sorted)
+	assert.Equal(t, encodeList(sorted), encoded.Bytes())
+
+	// Malformed records are rejected, and don't change the builder.
+	assert.Equal(t, io.EOF, builder.AddEncoded([]byte("\x03ab")))
+	assert.Equal(t, stuffed.InvalidRunLength, builder.AddEncoded([]byte("\xfd")))
+	assert.Equal(t, stuffed.EmbeddedDelimiter, builder.AddEncoded([]byte("\x02\xfe\xfd")))
+	builder.WriteString("partial")
+	assert.Equal(t, stuffed.UnfinishedRecord, builder.AddEncoded([]byte("\x00")))
+	builder.FinishRecord()
+	encoded.Reset()
+	builder.Encode(&encoded)
+	assert.Equal(t, encodeList(append(sorted, "partial")), encoded.Bytes())
+}
+
+func TestRecordBuilderReset(t *testing.T) {
+	builder := stuffed.NewRecordBuilder(stuffed.WithChecksum())
+	builder.WriteString("stale")
+	builder.FinishRecord()
+	builder.WriteString("unfinished")
+	builder.Reset()
+	assert.Equal(t, 0, builder.Len())
+	var encoded bytes.Buffer
+	builder.Encode(&encoded)
+	assert.Equal(t, 0, encoded.Len())
+
+	// A reset builder keeps its options, and can be used again.
+	builder.WriteString("abc")
+	builder.FinishRecord()
+	builder.Encode(&encoded)
+	var expected bytes.Buffer
+	stuffed.EncodeWithChecksum([]byte("abc"), &expected)
+	stuffed.EncodeDelimiter(&expected)
+	assert.Equal(t, expected.Bytes(), encoded.Bytes())
+}
+
+func TestRecordBuilderSortAndDedup(t *testing.T) {
+	var builder stuffed.RecordBuilder
+	assert.Equal(t, 0, builder.SortAndDedup())
+
+	for _, record := range []string{"b", "a", "c", "a", "", "b"} {
+		builder.WriteString(record)
+		builder.FinishRecord()
+	}
+	require.NoError(t, builder.AddEncoded(bytes.TrimSuffix(encodeList([]string{"c"}), delimiter)))
+	require.NoError(t, builder.AddEncoded(bytes.TrimSuffix(encodeList([]string{"d"}), delimiter)))
+	assert.Equal(t, 3, builder.SortAndDedup())
+	assert.Equal(t, 0, builder.SortAndDedup())
+
+	var encoded bytes.Buffer
+	offsets := builder.EncodeWithOffsets(&encoded)
+	actual, err := parseStrings(encoded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "a", "b", "c", "d"}, actual)
+	require.Len(t, offsets, 5)
+	for _, offset := range offsets {
+		assert.True(t, stuffed.IsStartOfRecord(encoded.Bytes(), offset))
+	}
+
+	// Records that we add after deduplicating are numbered after the ones that
+	// we kept.
+	builder.WriteString("e")
+	builder.FinishRecord()
+	encoded.Reset()
+	offsets = builder.EncodeWithOffsets(&encoded)
+	assert.ElementsMatch(t, []int{0, 3, 7, 11, 15, 19}, offsets)
+	assert.Equal(t, 19, offsets[5])
+}
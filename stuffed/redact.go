@@ -0,0 +1,86 @@
+package stuffed
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// Redactor sanitizes the decoded content of a record in place.  Redactors
+// cannot change the length of a record.
+type Redactor func(decoded []byte)
+
+// MaskRange returns a Redactor that overwrites the bytes in the range [From, To)
+// of each record with mask.  Any part of the range that extends past the end of
+// a record is ignored.
+func MaskRange(rng Range, mask byte) Redactor {
+	return func(decoded []byte) {
+		field := clampRange(decoded, rng)
+		for i := range field {
+			field[i] = mask
+		}
+	}
+}
+
+// HashRange returns a Redactor that replaces the bytes in the range [From, To)
+// of each record with bytes derived from an HMAC-SHA256 of their original
+// content, using key.  Equal fields are always replaced with equal bytes, so
+// redacted records can still be joined on the field.  Any part of the range
+// that extends past the end of a record is ignored.
+//
+// This is pseudonymization, not redaction: anyone who knows the key can
+// recover a low-entropy field (such as a phone number) by hashing every
+// possible value.  Keep the key secret, and use a different key for each
+// sanitized copy that shouldn't be joinable with the others.
+func HashRange(rng Range, key []byte) Redactor {
+	key = append([]byte{}, key...)
+	return func(decoded []byte) {
+		field := clampRange(decoded, rng)
+		mac := hmac.New(sha256.New, key)
+		mac.Write(field)
+		digest := mac.Sum(nil)
+		for i := range field {
+			field[i] = digest[i%len(digest)]
+		}
+	}
+}
+
+func clampRange(decoded []byte, rng Range) []byte {
+	from, to := rng.From, rng.To
+	if to > len(decoded) {
+		to = len(decoded)
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from >= to {
+		return nil
+	}
+	return decoded[from:to]
+}
+
+// Redact produces a sanitized copy of a buffer containing a list of delimited
+// stuffed records, by decoding each record, applying each of the redactors to
+// it, and encoding the result.  The output contains exactly as many records as
+// the input, in the same order.  If the list is sorted, and the redactors leave
+// the sort key untouched, the output will be sorted as well.
+func Redact(encodedList []byte, redactors ...Redactor) ([]byte, error) {
+	var result bytes.Buffer
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var s Scanner
+	s.Reset(encodedList)
+	for s.Next() {
+		decoded.Reset()
+		if err := s.Decode(decoded); err != nil {
+			return nil, err
+		}
+		for _, redact := range redactors {
+			redact(decoded.Bytes())
+		}
+		Encode(decoded.Bytes(), &result)
+		EncodeDelimiter(&result)
+	}
+	return result.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package stuffed_test
+
+import (
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	inputList := []string{"key1 secret", "key2 secret", "key3 other!", "k"}
+	encoded := encodeList(inputList)
+
+	redacted, err := stuffed.Redact(encoded, stuffed.MaskRange(stuffed.Range{From: 5, To: 100}, '*'))
+	require.NoError(t, err)
+	actual, err := parseStrings(redacted)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key1 ******", "key2 ******", "key3 ******", "k"}, actual)
+
+	key := []byte("redaction key")
+	redacted, err = stuffed.Redact(encoded, stuffed.HashRange(stuffed.Range{From: 5, To: 11}, key))
+	require.NoError(t, err)
+	actual, err = parseStrings(redacted)
+	require.NoError(t, err)
+	require.Len(t, actual, len(inputList))
+	for i := range inputList {
+		assert.Equal(t, len(inputList[i]), len(actual[i]))
+		assert.Equal(t, inputList[i][:minInt(5, len(inputList[i]))], actual[i][:minInt(5, len(actual[i]))])
+	}
+	assert.NotEqual(t, inputList[0], actual[0])
+	assert.Equal(t, actual[0][5:], actual[1][5:])
+	assert.NotEqual(t, actual[0][5:], actual[2][5:])
+
+	// A different key produces different bytes.
+	otherKey, err := stuffed.Redact(encoded, stuffed.HashRange(stuffed.Range{From: 5, To: 11}, []byte("other key")))
+	require.NoError(t, err)
+	assert.NotEqual(t, redacted, otherKey)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,129 @@
+package stuffed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"sort"
+)
+
+var (
+	// InvalidHashRing is the error that is returned when decoding a malformed
+	// consistent-hash ring.
+	InvalidHashRing = errors.New("Invalid hash ring")
+)
+
+// HashRing is a consistent-hash ring, which assigns each key to the node that
+// owns the first token at or after the key's hash (wrapping around at the end
+// of the ring).  A node can own any number of tokens.  You can store a ring as
+// a sorted list of stuffed records using Encode, and load it again using
+// DecodeHashRing.  Once you've finished adding tokens, a HashRing is
+// read-only, so many goroutines can look up keys at once, but not while
+// another goroutine is calling Add.
+type HashRing struct {
+	tokens []uint64
+	nodes  []string
+}
+
+// Add assigns a token to a node.  If the token is already assigned to another
+// node, it is reassigned.
+func (r *HashRing) Add(node string, token uint64) {
+	i := sort.Search(len(r.tokens), func(i int) bool {
+		return r.tokens[i] >= token
+	})
+	if i < len(r.tokens) && r.tokens[i] == token {
+		r.nodes[i] = node
+		return
+	}
+	r.tokens = append(r.tokens, 0)
+	copy(r.tokens[i+1:], r.tokens[i:])
+	r.tokens[i] = token
+	r.nodes = append(r.nodes, "")
+	copy(r.nodes[i+1:], r.nodes[i:])
+	r.nodes[i] = node
+}
+
+// Len returns the number of tokens in the ring.
+func (r *HashRing) Len() int {
+	return len(r.tokens)
+}
+
+// Tokens returns the sorted list of tokens owned by a node.
+func (r *HashRing) Tokens(node string) []uint64 {
+	var result []uint64
+	for i := range r.tokens {
+		if r.nodes[i] == node {
+			result = append(result, r.tokens[i])
+		}
+	}
+	return result
+}
+
+// HashKey returns the position of a key on a HashRing.  This is a stable
+// 64-bit FNV-1a hash, the same one used by ShardByKeyHash.
+func HashKey(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64()
+}
+
+// Lookup returns the node that owns a key.  It returns false if the ring is
+// empty.
+func (r *HashRing) Lookup(key []byte) (string, bool) {
+	if len(r.tokens) == 0 {
+		return "", false
+	}
+	hash := HashKey(key)
+	i := sort.Search(len(r.tokens), func(i int) bool {
+		return r.tokens[i] >= hash
+	})
+	if i == len(r.tokens) {
+		i = 0
+	}
+	return r.nodes[i], true
+}
+
+// Encode writes the ring into an output buffer as a sorted list of delimited
+// stuffed records, one per token.  Each record's decoded content is the
+// token's big-endian 8-byte encoding followed by the name of its node.
+func (r *HashRing) Encode(dest *bytes.Buffer) {
+	scratch := getScratch()
+	defer putScratch(scratch)
+	record := &scratch.a
+	for i := range r.tokens {
+		record.Reset()
+		var token [8]byte
+		binary.BigEndian.PutUint64(token[:], r.tokens[i])
+		record.Write(token[:])
+		record.WriteString(r.nodes[i])
+		Encode(record.Bytes(), dest)
+		EncodeDelimiter(dest)
+	}
+}
+
+// DecodeHashRing loads a ring that was written by HashRing.Encode.
+func DecodeHashRing(encodedList []byte) (*HashRing, error) {
+	r := &HashRing{}
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var s Scanner
+	s.Reset(encodedList)
+	for s.Next() {
+		decoded.Reset()
+		if err := s.Decode(decoded); err != nil {
+			return nil, err
+		}
+		if decoded.Len() < 8 {
+			return nil, InvalidHashRing
+		}
+		token := binary.BigEndian.Uint64(decoded.Bytes())
+		if len(r.tokens) > 0 && token <= r.tokens[len(r.tokens)-1] {
+			return nil, InvalidHashRing
+		}
+		r.tokens = append(r.tokens, token)
+		r.nodes = append(r.nodes, string(decoded.Bytes()[8:]))
+	}
+	return r, nil
+}
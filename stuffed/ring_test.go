@@ -0,0 +1,53 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashRing(t *testing.T) {
+	var empty stuffed.HashRing
+	_, ok := empty.Lookup([]byte("key"))
+	assert.False(t, ok)
+
+	var ring stuffed.HashRing
+	ring.Add("node-c", 1<<63)
+	ring.Add("node-a", 1<<62)
+	ring.Add("node-b", 3<<62)
+	ring.Add("node-a", 1<<60)
+	assert.Equal(t, 4, ring.Len())
+	assert.Equal(t, []uint64{1 << 60, 1 << 62}, ring.Tokens("node-a"))
+
+	var encoded bytes.Buffer
+	ring.Encode(&encoded)
+	loaded, err := stuffed.DecodeHashRing(encoded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, &ring, loaded)
+
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key %d", i))
+		node, ok := loaded.Lookup(key)
+		require.True(t, ok)
+		hash := stuffed.HashKey(key)
+		switch {
+		case hash <= 1<<60:
+			assert.Equal(t, "node-a", node)
+		case hash <= 1<<62:
+			assert.Equal(t, "node-a", node)
+		case hash <= 1<<63:
+			assert.Equal(t, "node-c", node)
+		case hash <= 3<<62:
+			assert.Equal(t, "node-b", node)
+		default:
+			assert.Equal(t, "node-a", node)
+		}
+	}
+
+	_, err = stuffed.DecodeHashRing(encodeList([]string{"short"}))
+	assert.Equal(t, stuffed.InvalidHashRing, err)
+}
@@ -0,0 +1,135 @@
+package stuffed
+
+import (
+	"io"
+)
+
+// delimiterBytes is the delimiter sequence as a byte slice.  It must not be
+// modified.
+var delimiterBytes = []byte{delimiter0, delimiter1}
+
+// chunkReader walks through the decoded content of an encoded stuffed record
+// without copying it anywhere.  Each chunk is either the content of one run, or
+// a delimiter that was removed from in between two runs.
+type chunkReader struct {
+	encoded          []byte
+	codec            *Codec
+	runs             int
+	started          bool
+	done             bool
+	pendingDelimiter bool
+}
+
+func newChunkReader(encoded []byte) chunkReader {
+	return chunkReader{encoded: encoded}
+}
+
+// digit returns the value of a run header digit.  (For the default delimiter,
+// each digit is represented by the byte with the same value.)  It returns false
+// if the byte doesn't represent a digit.
+func (r *chunkReader) digit(b byte) (int, bool) {
+	if r.codec == nil {
+		return int(b), true
+	}
+	return r.codec.digit(b)
+}
+
+// next returns the next chunk of the record's decoded content, or false if we
+// have reached the end of the record.  Chunks can be empty.
+func (r *chunkReader) next() ([]byte, bool, error) {
+	if r.pendingDelimiter {
+		r.pendingDelimiter = false
+		return r.codec.delim(), true, nil
+	}
+	if r.done {
+		return nil, false, nil
+	}
+
+	var runLength, maxRun int
+	if !r.started {
+		// For the first run, the length is one byte.
+		r.started = true
+		if len(r.encoded) < 1 {
+			return nil, false, io.EOF
+		}
+		low, ok := r.digit(r.encoded[0])
+		if !ok {
+			return nil, false, InvalidRunLength
+		}
+		r.runs++
+		runLength = low
+		r.encoded = r.encoded[1:]
+		maxRun = maxInitialRun
+	} else {
+		if len(r.encoded) < delimiterLength {
+			return nil, false, io.EOF
+		}
+		low, okLow := r.digit(r.encoded[0])
+		high, okHigh := r.digit(r.encoded[1])
+		if !okLow || !okHigh {
+			return nil, false, InvalidRunLength
+		}
+		r.runs++
+		runLength = low + radix*high
+		r.encoded = r.encoded[delimiterLength:]
+		maxRun = maxRemainingRun
+	}
+	if runLength > maxRun {
+		return nil, false, InvalidRunLength
+	}
+
+	if len(r.encoded) < runLength {
+		return nil, false, io.EOF
+	}
+	chunk := r.encoded[:runLength]
+	r.encoded = r.encoded[runLength:]
+	if runLength < maxRun {
+		if len(r.encoded) == 0 {
+			r.done = true
+		} else {
+			r.pendingDelimiter = true
+		}
+	}
+	return chunk, true, nil
+}
+
+// nonEmpty returns chunk if it's not empty, and otherwise returns the next
+// non-empty chunk of the record's decoded content.  It returns false if we have
+// reached the end of the record.
+func (r *chunkReader) nonEmpty(chunk []byte) ([]byte, bool, error) {
+	for len(chunk) == 0 {
+		var ok bool
+		var err error
+		chunk, ok, err = r.next()
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+	}
+	return chunk, true, nil
+}
+
+// DecodedLen returns the exact length of a stuffed record's decoded content,
+// using only its run headers, without copying any of its content.  You can use
+// this to size an output buffer before calling Decode.  Returns the same errors
+// as Decode for a malformed record.
+func DecodedLen(encoded []byte) (int, error) {
+	var c *Codec
+	return c.DecodedLen(encoded)
+}
+
+// DecodedLen returns the exact length of the decoded content of a record that
+// was encoded with this Codec, using only its run headers.
+func (c *Codec) DecodedLen(encoded []byte) (int, error) {
+	result := 0
+	r := c.chunks(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return result, nil
+		}
+		result += len(chunk)
+	}
+}
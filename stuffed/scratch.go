@@ -0,0 +1,55 @@
+package stuffed
+
+import (
+	"bytes"
+	"sync"
+)
+
+// scratch holds temporary buffers that a function needs while it runs, but
+// which don't outlive the call.  We keep them in a pool, so that (for
+// instance) running many queries doesn't allocate new decoding buffers for
+// each one.  Each call gets its own scratch, so this is safe for concurrent
+// use.
+//
+// Building with the stuffed_nopool tag disables pooling, so that every call
+// allocates fresh buffers.  That makes it easier to find code that holds on to
+// a scratch buffer after returning it, using the race detector or a memory
+// sanitizer.
+type scratch struct {
+	a, b bytes.Buffer
+	raw  []byte
+}
+
+// maxPooledScratch is the largest buffer that we return to the pool.  Larger
+// buffers are left for the garbage collector, so that one huge record doesn't
+// pin its memory forever.
+const maxPooledScratch = 64 << 10
+
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		return new(scratch)
+	},
+}
+
+// getScratch returns an empty scratch.  You must not use it after passing it
+// to putScratch.
+func getScratch() *scratch {
+	if !poolScratch {
+		return new(scratch)
+	}
+	return scratchPool.Get().(*scratch)
+}
+
+// putScratch returns a scratch to the pool.
+func putScratch(s *scratch) {
+	if !poolScratch {
+		return
+	}
+	if s.a.Cap() > maxPooledScratch || s.b.Cap() > maxPooledScratch || cap(s.raw) > maxPooledScratch {
+		return
+	}
+	s.a.Reset()
+	s.b.Reset()
+	s.raw = s.raw[:0]
+	scratchPool.Put(s)
+}
@@ -0,0 +1,7 @@
+//go:build !stuffed_nopool
+// +build !stuffed_nopool
+
+package stuffed
+
+// poolScratch controls whether we reuse scratch buffers.
+const poolScratch = true
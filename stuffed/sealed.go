@@ -0,0 +1,106 @@
+package stuffed
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrUnsealFailed is the error that is returned when a sealed record can't
+	// be opened, because it's too short, it has been tampered with, or it was
+	// sealed with a different key.
+	ErrUnsealFailed = errors.New("Cannot unseal record")
+)
+
+// SealedCodec encrypts and authenticates the content of each record using an
+// AEAD cipher, such as AES-GCM.  Each record is sealed with a fresh random
+// nonce, which we store at the start of the record's content, before stuffing.
+// That means that each record can be opened by itself, and that a list of
+// sealed records can still be delimited, scanned, and resynchronized without
+// the key.
+//
+// Sealing protects each record by itself, not the list as a whole.  Someone
+// who can modify the list can reorder, drop, or duplicate sealed records, or
+// move them from one list to another, without us noticing.  To bind records to
+// a particular list (or a particular position in it), use WithAssociatedData.
+//
+// Since the nonces are random, you shouldn't seal more than about 2^32 records
+// with the same key if your AEAD uses 96-bit nonces (as AES-GCM does).  After
+// that, the chance of reusing a nonce, which breaks the cipher's guarantees,
+// is no longer negligible.
+//
+// SealedCodec implements Transform, so you can pass it to WithTransform to seal
+// the records in a RecordBuilder, or to open them in a Scanner or Reader.  A
+// SealedCodec is safe for concurrent use if its AEAD is.  (The AEADs in the
+// standard library are.)
+type SealedCodec struct {
+	aead           cipher.AEAD
+	associatedData []byte
+}
+
+// NewSealedCodec creates a new SealedCodec that seals records using aead.
+func NewSealedCodec(aead cipher.AEAD) *SealedCodec {
+	return &SealedCodec{aead: aead}
+}
+
+// WithAssociatedData returns a SealedCodec that uses the same AEAD, but
+// authenticates data along with each record.  The data isn't stored in the
+// record, so you must provide the same data to open it.  For instance, you can
+// use a list's ID to keep records from being moved between lists, or the ID
+// plus a record's position to keep them from being reordered as well.
+func (c *SealedCodec) WithAssociatedData(data []byte) *SealedCodec {
+	return &SealedCodec{aead: c.aead, associatedData: append([]byte{}, data...)}
+}
+
+// Encode seals a binary record, and writes it to an output buffer using the
+// stuffed records encoding.
+func (c *SealedCodec) Encode(record []byte, dest *bytes.Buffer) {
+	EncodeWithTransform(record, dest, c)
+}
+
+// Decode reads a binary record that was written by Encode, and opens it.  If
+// the record can't be opened, we return ErrUnsealFailed and leave the output
+// buffer unchanged.
+func (c *SealedCodec) Decode(encoded []byte, record *bytes.Buffer) error {
+	return DecodeWithTransform(encoded, record, c)
+}
+
+// Apply seals a record, returning the nonce followed by the ciphertext.  We
+// panic if we can't read a random nonce, since that means that the system's
+// random number generator is broken.
+func (c *SealedCodec) Apply(record []byte) []byte {
+	nonceSize := c.aead.NonceSize()
+	sealed := make([]byte, nonceSize, nonceSize+len(record)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, sealed); err != nil {
+		panic(err)
+	}
+	return c.aead.Seal(sealed, sealed, record, c.associatedData)
+}
+
+// Invert opens a record that was sealed by Apply.  We know the size of the
+// record before opening it, so we return an ErrRecordTooLarge error without
+// opening any record that's larger than max.
+func (c *SealedCodec) Invert(sealed []byte, max int) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize+c.aead.Overhead() {
+		return nil, ErrUnsealFailed
+	}
+	if size := len(sealed) - nonceSize - c.aead.Overhead(); size > max {
+		return nil, &ErrRecordTooLarge{Size: size, Limit: max}
+	}
+	nonce, ciphertext := sealed[:nonceSize], sealed[nonceSize:]
+	record, err := c.aead.Open(nil, nonce, ciphertext, c.associatedData)
+	if err != nil {
+		return nil, ErrUnsealFailed
+	}
+	return record, nil
+}
+
+// MaxTransformedLen returns the size of a sealed n-byte record, which is the
+// same for every record of that size.
+func (c *SealedCodec) MaxTransformedLen(n int) int {
+	return c.aead.NonceSize() + n + c.aead.Overhead()
+}
@@ -0,0 +1,80 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAEAD(t *testing.T, key string) cipher.AEAD {
+	block, err := aes.NewCipher([]byte(key))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func TestSealedCodec(t *testing.T) {
+	codec := stuffed.NewSealedCodec(newTestAEAD(t, "0123456789abcdef"))
+
+	var encoded bytes.Buffer
+	for _, input := range shortTestCaseInputs() {
+		codec.Encode([]byte(input), &encoded)
+		stuffed.EncodeDelimiter(&encoded)
+	}
+
+	var actual []string
+	s := stuffed.NewScanner(encoded.Bytes(), stuffed.WithTransform(codec))
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, shortTestCaseInputs(), actual)
+
+	// Sealing the same record twice uses different nonces.
+	var first, second bytes.Buffer
+	codec.Encode([]byte("abc"), &first)
+	codec.Encode([]byte("abc"), &second)
+	assert.NotEqual(t, first.String(), second.String())
+
+	// Tampered records don't open, and leave the output unchanged.
+	tampered := append([]byte{}, first.Bytes()...)
+	tampered[len(tampered)-1] ^= 0x01
+	decoded := bytes.NewBufferString("prefix")
+	assert.Equal(t, stuffed.ErrUnsealFailed, codec.Decode(tampered, decoded))
+	assert.Equal(t, "prefix", decoded.String())
+
+	// So do records sealed with a different key, and records that are too
+	// short to contain a nonce.
+	other := stuffed.NewSealedCodec(newTestAEAD(t, "fedcba9876543210"))
+	assert.Equal(t, stuffed.ErrUnsealFailed, other.Decode(first.Bytes(), decoded))
+	assert.Equal(t, stuffed.ErrUnsealFailed, codec.Decode([]byte("\x03abc"), decoded))
+	assert.Equal(t, "prefix", decoded.String())
+
+	// Records sealed with associated data only open with the same data.
+	var bound bytes.Buffer
+	listA := codec.WithAssociatedData([]byte("list A"))
+	listA.Encode([]byte("abc"), &bound)
+	assert.Equal(t, stuffed.ErrUnsealFailed, codec.Decode(bound.Bytes(), decoded))
+	assert.Equal(t, stuffed.ErrUnsealFailed, codec.WithAssociatedData([]byte("list B")).Decode(bound.Bytes(), decoded))
+	assert.Equal(t, stuffed.ErrUnsealFailed, listA.Decode(first.Bytes(), decoded))
+	assert.Equal(t, "prefix", decoded.String())
+	decoded.Reset()
+	require.NoError(t, codec.WithAssociatedData([]byte("list A")).Decode(bound.Bytes(), decoded))
+	assert.Equal(t, "abc", decoded.String())
+	decoded = bytes.NewBufferString("prefix")
+
+	// We don't open records that are larger than the limit.
+	var large bytes.Buffer
+	codec.Encode([]byte("abcdef"), &large)
+	s = stuffed.NewScanner(large.Bytes(), stuffed.WithTransform(codec), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 5}))
+	require.True(t, s.Next())
+	assert.IsType(t, &stuffed.ErrRecordTooLarge{}, s.Decode(decoded))
+	assert.Equal(t, "prefix", decoded.String())
+}
@@ -0,0 +1,55 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+)
+
+var (
+	// InvalidShardCount is the error that is returned when you ask to divide a
+	// list into fewer than one shard.
+	InvalidShardCount = errors.New("Invalid shard count")
+)
+
+// KeyFunc derives a key from the decoded content of a record.  The key can be
+// a subslice of decoded.  Neither can be retained after the call returns.
+type KeyFunc func(decoded []byte) []byte
+
+// ShardByKeyHash divides a buffer containing a list of delimited stuffed
+// records into shards, routing each record to a shard based on a stable hash of
+// its key.  (If keyFn is nil, the key is the record's entire decoded content.)
+// The encoded content of each record is copied into its shard verbatim, and the
+// records in each shard keep their relative order, so sharding a sorted list
+// produces sorted shards.  The hash is stable across processes and releases, so
+// the same key is always routed to the same shard.
+func ShardByKeyHash(encodedList []byte, shards int, keyFn KeyFunc) ([][]byte, error) {
+	if shards < 1 {
+		return nil, InvalidShardCount
+	}
+
+	buffers := make([]bytes.Buffer, shards)
+	scratch := getScratch()
+	defer putScratch(scratch)
+	decoded := &scratch.a
+	var s Scanner
+	s.Reset(encodedList)
+	for s.Next() {
+		decoded.Reset()
+		if err := s.Decode(decoded); err != nil {
+			return nil, err
+		}
+		key := decoded.Bytes()
+		if keyFn != nil {
+			key = keyFn(key)
+		}
+		shard := &buffers[HashKey(key)%uint64(shards)]
+		shard.Write(s.Encoded())
+		EncodeDelimiter(shard)
+	}
+
+	result := make([][]byte, shards)
+	for i := range buffers {
+		result[i] = buffers[i].Bytes()
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyBeforeSpace(decoded []byte) []byte {
+	if i := bytes.IndexByte(decoded, ' '); i != -1 {
+		return decoded[:i]
+	}
+	return decoded
+}
+
+func TestShardByKeyHash(t *testing.T) {
+	inputList := []string{"a 1", "a 2", "b 1", "c 1", "a 3", "d", "b 2", "e\xfe\xfd 1"}
+	encoded := encodeList(inputList)
+
+	shards, err := stuffed.ShardByKeyHash(encoded, 3, keyBeforeSpace)
+	require.NoError(t, err)
+	require.Len(t, shards, 3)
+
+	var all []string
+	shardOfKey := make(map[string]int)
+	for i, shard := range shards {
+		records, err := parseStrings(shard)
+		require.NoError(t, err)
+		for _, record := range records {
+			key := string(keyBeforeSpace([]byte(record)))
+			if previous, ok := shardOfKey[key]; ok {
+				assert.Equal(t, previous, i, key)
+			}
+			shardOfKey[key] = i
+		}
+		all = append(all, records...)
+	}
+	sort.Strings(all)
+	sorted := append([]string{}, inputList...)
+	sort.Strings(sorted)
+	assert.Equal(t, sorted, all)
+
+	// The same input should always produce the same shards.
+	again, err := stuffed.ShardByKeyHash(encoded, 3, keyBeforeSpace)
+	require.NoError(t, err)
+	assert.Equal(t, shards, again)
+
+	_, err = stuffed.ShardByKeyHash(encoded, 0, nil)
+	assert.Equal(t, stuffed.InvalidShardCount, err)
+}
@@ -0,0 +1,152 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	// OutOfOrder is the error that is returned when you try to add a record to
+	// a SortedWriter that sorts before the previous record.
+	OutOfOrder = errors.New("Record is out of order")
+
+	// ErrQuotaExceeded is the error that is returned when writing a record
+	// would exceed a SortedWriter's quota.
+	ErrQuotaExceeded = errors.New("Quota exceeded")
+)
+
+// ErrUnsorted is the error that is returned by FindRecordsWithPrefixChecked
+// when it finds records that are out of order.
+type ErrUnsorted struct {
+	// First and Second are the offsets of two records in the list.  First
+	// comes before Second in the list, but sorts after it.
+	First, Second int
+}
+
+func (e *ErrUnsorted) Error() string {
+	return fmt.Sprintf("Records at offsets %d and %d are out of order", e.First, e.Second)
+}
+
+// SortedWriter encodes a sequence of records into an output buffer, verifying
+// that you provide them in sorted order.  This guarantees that the output is
+// valid input for FindRecordsWithPrefix.  (If you can't produce your records in
+// order, use a RecordBuilder and call its Sort method instead.)  A SortedWriter
+// is not safe for concurrent use.
+type SortedWriter struct {
+	dest         *bytes.Buffer
+	previous     []byte
+	started      bool
+	maxBytes     int
+	maxRecords   int
+	bytesWritten int
+	records      int
+
+	// warnFraction and onWarning configure the quota warnings, and
+	// warnedBytes and warnedRecords record which ones we've already sent.
+	warnFraction  float64
+	onWarning     func(LimitWarning)
+	warnedBytes   bool
+	warnedRecords bool
+}
+
+// NewSortedWriter creates a new SortedWriter that writes encoded records into
+// dest.
+func NewSortedWriter(dest *bytes.Buffer) *SortedWriter {
+	return &SortedWriter{dest: dest}
+}
+
+// SetQuota limits the total number of encoded bytes (including delimiters) and
+// the number of records that this writer will write.  A zero value means that
+// there is no limit.
+func (w *SortedWriter) SetQuota(maxBytes, maxRecords int) {
+	w.maxBytes = maxBytes
+	w.maxRecords = maxRecords
+	w.warnedBytes = false
+	w.warnedRecords = false
+}
+
+// SetQuotaWarning causes the writer to call onWarning when it's about to run
+// out of quota, just like the OnWarning callback of a Limits.  We call it once
+// for each quota, the first time that a write brings the writer's usage to at
+// least fraction of that quota.  The warning's Limit is "MaxBytes" or
+// "MaxRecords".  Calling SetQuota again rearms the warnings.
+func (w *SortedWriter) SetQuotaWarning(fraction float64, onWarning func(LimitWarning)) {
+	w.warnFraction = fraction
+	w.onWarning = onWarning
+}
+
+// warnIfClose calls onWarning if a quota has crossed its warning threshold for
+// the first time.
+func (w *SortedWriter) warnIfClose(name string, used, max int, warned *bool) {
+	if w.onWarning == nil || w.warnFraction <= 0 || max <= 0 || *warned {
+		return
+	}
+	if float64(used) >= w.warnFraction*float64(max) {
+		*warned = true
+		w.onWarning(LimitWarning{Limit: name, Used: used, Max: max})
+	}
+}
+
+// WriteRecord encodes a record into the output buffer, followed by a
+// delimiter.  If the record sorts before the previous record, we return
+// OutOfOrder; if it would exceed the writer's quota, we return
+// ErrQuotaExceeded.  Either way, we leave the output buffer untouched.
+// Duplicate records are allowed.
+func (w *SortedWriter) WriteRecord(record []byte) error {
+	return w.write(func(dest *bytes.Buffer) {
+		Encode(record, dest)
+	})
+}
+
+// WriteEncoded writes a record that has already been encoded using the stuffed
+// records encoding, followed by a delimiter, just like WriteRecord.  We copy
+// its encoded content as-is, without decoding it and re-encoding it.  If the
+// record is malformed, we return an error and leave the output buffer
+// untouched.
+func (w *SortedWriter) WriteEncoded(encoded []byte) error {
+	if FindDelimiter(encoded) != -1 {
+		return EmbeddedDelimiter
+	}
+	if _, err := DecodedLen(encoded); err != nil {
+		return err
+	}
+	return w.write(func(dest *bytes.Buffer) {
+		dest.Write(encoded)
+	})
+}
+
+// write uses encode to write a record into the output buffer, and then checks
+// that it's in order and within the quota.  We keep the encoded content of the
+// previous record, so that we can compare records in either form without
+// decoding them.
+func (w *SortedWriter) write(encode func(dest *bytes.Buffer)) error {
+	start := w.dest.Len()
+	encode(w.dest)
+	encoded := w.dest.Bytes()[start:]
+	if w.started {
+		// Both records are well-formed, so this can't fail.
+		if cmp, _ := CompareEncoded(encoded, w.previous); cmp < 0 {
+			w.dest.Truncate(start)
+			return OutOfOrder
+		}
+	}
+	if w.maxRecords > 0 && w.records >= w.maxRecords {
+		w.dest.Truncate(start)
+		return ErrQuotaExceeded
+	}
+	length := len(encoded)
+	EncodeDelimiter(w.dest)
+	written := w.dest.Len() - start
+	if w.maxBytes > 0 && w.bytesWritten+written > w.maxBytes {
+		w.dest.Truncate(start)
+		return ErrQuotaExceeded
+	}
+	w.previous = append(w.previous[:0], w.dest.Bytes()[start:start+length]...)
+	w.bytesWritten += written
+	w.records++
+	w.started = true
+	w.warnIfClose("MaxBytes", w.bytesWritten, w.maxBytes, &w.warnedBytes)
+	w.warnIfClose("MaxRecords", w.records, w.maxRecords, &w.warnedRecords)
+	return nil
+}
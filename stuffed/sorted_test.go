@@ -0,0 +1,137 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedWriter(t *testing.T) {
+	var encoded bytes.Buffer
+	w := stuffed.NewSortedWriter(&encoded)
+	require.NoError(t, w.WriteRecord([]byte("abc")))
+	require.NoError(t, w.WriteRecord([]byte("abc")))
+	require.NoError(t, w.WriteRecord([]byte("abd")))
+
+	length := encoded.Len()
+	assert.Equal(t, stuffed.OutOfOrder, w.WriteRecord([]byte("abc\xfe\xfd")))
+	assert.Equal(t, length, encoded.Len())
+
+	require.NoError(t, w.WriteRecord([]byte("b")))
+	actual, err := parseStrings(encoded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "abc", "abd", "b"}, actual)
+
+	matching, err := stuffed.FindRecordsWithPrefix(encoded.Bytes(), []byte("ab"))
+	require.NoError(t, err)
+	actual, err = parseStrings(matching)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "abc", "abd"}, actual)
+}
+
+func TestSortedWriterEncoded(t *testing.T) {
+	var encoded bytes.Buffer
+	w := stuffed.NewSortedWriter(&encoded)
+	require.NoError(t, w.WriteRecord([]byte("abc")))
+	require.NoError(t, w.WriteEncoded([]byte("\x03abd")))
+	require.NoError(t, w.WriteRecord([]byte("abd\xfe\xfd")))
+
+	length := encoded.Len()
+	assert.Equal(t, stuffed.OutOfOrder, w.WriteEncoded([]byte("\x03abd")))
+	assert.Equal(t, stuffed.EmbeddedDelimiter, w.WriteEncoded([]byte("\x03a\xfe\xfd")))
+	assert.Equal(t, stuffed.InvalidRunLength, w.WriteEncoded([]byte("\xff")))
+	assert.Equal(t, length, encoded.Len())
+
+	require.NoError(t, w.WriteEncoded([]byte("\x01b")))
+	actual, err := parseStrings(encoded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "abd", "abd\xfe\xfd", "b"}, actual)
+}
+
+func TestSortedWriterQuota(t *testing.T) {
+	var encoded bytes.Buffer
+	encoded.WriteString("existing content")
+	w := stuffed.NewSortedWriter(&encoded)
+	w.SetQuota(12, 0)
+	require.NoError(t, w.WriteRecord([]byte("a")))
+	require.NoError(t, w.WriteRecord([]byte("a")))
+	length := encoded.Len()
+	assert.Equal(t, stuffed.ErrQuotaExceeded, w.WriteRecord([]byte("abc")))
+	assert.Equal(t, length, encoded.Len())
+	require.NoError(t, w.WriteRecord([]byte("b")))
+	assert.Equal(t, stuffed.ErrQuotaExceeded, w.WriteRecord([]byte("c")))
+
+	encoded.Reset()
+	w = stuffed.NewSortedWriter(&encoded)
+	w.SetQuota(0, 2)
+	require.NoError(t, w.WriteRecord([]byte("abc")))
+	require.NoError(t, w.WriteRecord([]byte("abd")))
+	assert.Equal(t, stuffed.ErrQuotaExceeded, w.WriteRecord([]byte("abe")))
+	actual, err := parseStrings(encoded.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "abd"}, actual)
+}
+
+func TestSortedWriterQuotaWarning(t *testing.T) {
+	var encoded bytes.Buffer
+	w := stuffed.NewSortedWriter(&encoded)
+	w.SetQuota(20, 4)
+	var warnings []stuffed.LimitWarning
+	w.SetQuotaWarning(0.5, func(warning stuffed.LimitWarning) {
+		warnings = append(warnings, warning)
+	})
+
+	// Each record takes 4 bytes, including its delimiter.
+	require.NoError(t, w.WriteRecord([]byte("a")))
+	assert.Empty(t, warnings)
+	require.NoError(t, w.WriteRecord([]byte("b")))
+	assert.Equal(t, []stuffed.LimitWarning{{Limit: "MaxRecords", Used: 2, Max: 4}}, warnings)
+	require.NoError(t, w.WriteRecord([]byte("c")))
+	assert.Equal(t, []stuffed.LimitWarning{
+		{Limit: "MaxRecords", Used: 2, Max: 4},
+		{Limit: "MaxBytes", Used: 12, Max: 20},
+	}, warnings)
+
+	// We only warn once for each quota.
+	require.NoError(t, w.WriteRecord([]byte("d")))
+	assert.Len(t, warnings, 2)
+}
+
+func TestFindRecordsWithPrefixChecked(t *testing.T) {
+	for _, tc := range prefixTestCases {
+		inputList := shortTestCaseInputs()
+		sort.Strings(inputList)
+		encoded := encodeList(inputList)
+		expected, err := stuffed.FindRecordsWithPrefix(encoded, []byte(tc.prefix))
+		require.NoError(t, err)
+		actual, err := stuffed.FindRecordsWithPrefixChecked(encoded, []byte(tc.prefix))
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	testCases := []struct {
+		inputList     []string
+		prefix        string
+		first, second int
+	}{
+		// The binary search looks at "b" first, which sorts after "a".
+		{[]string{"c", "b", "a"}, "b", 4, 8},
+		// The record after the matching records sorts before them.
+		{[]string{"a", "b", "b", "a"}, "b", 8, 12},
+		// The record before the matching records sorts after them.  (The
+		// binary search never looks at it.)
+		{[]string{"a", "c", "c", "b", "b", "b", "b"}, "b", 8, 12},
+		// Every record matches an empty prefix, but we still check the
+		// records that the binary search would look at.
+		{[]string{"c", "b", "a"}, "", 4, 8},
+	}
+	for _, tc := range testCases {
+		encoded := encodeList(tc.inputList)
+		_, err := stuffed.FindRecordsWithPrefixChecked(encoded, []byte(tc.prefix))
+		assert.Equal(t, &stuffed.ErrUnsorted{First: tc.first, Second: tc.second}, err, "%q", tc.inputList)
+	}
+}
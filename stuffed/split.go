@@ -0,0 +1,56 @@
+package stuffed
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// SplitFunc returns SplitRecords as a bufio.SplitFunc.
+func SplitFunc() bufio.SplitFunc {
+	return SplitRecords
+}
+
+// SplitRecords is a bufio.SplitFunc that splits a stream of delimited stuffed
+// records into individual records, so that you can read stuffed records using
+// a bufio.Scanner.  Each token is the encoded content of one record, which you
+// can pass to Decode.  Just like Scanner, we skip over any empty space between
+// consecutive delimiters.  A delimiter that is split across two reads is
+// handled correctly, since we never return a record until we've seen the
+// entire delimiter that follows it (or the end of the stream).
+func SplitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	return splitRecords(data, atEOF, delimiterBytes)
+}
+
+// SplitRecords is a bufio.SplitFunc that splits a stream of records that were
+// encoded with this Codec, just like the package-level SplitRecords.
+func (c *Codec) SplitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	return splitRecords(data, atEOF, c.delim())
+}
+
+// splitRecords implements SplitRecords for records separated by delim.
+func splitRecords(data []byte, atEOF bool, delim []byte) (int, []byte, error) {
+	// Skip over any leading delimiters.
+	start := 0
+	for bytes.HasPrefix(data[start:], delim) {
+		start += len(delim)
+	}
+
+	index := bytes.Index(data[start:], delim)
+	if index != -1 {
+		end := start + index
+		return end, data[start:end], nil
+	}
+
+	if atEOF {
+		// Whatever's left at the end of the stream is the last record.
+		if start < len(data) {
+			return len(data), data[start:], nil
+		}
+		return len(data), nil, nil
+	}
+
+	// We need more data to find the end of the record.  (The record's
+	// delimiter might be split across two reads.)  We can still discard any
+	// leading delimiters that we've already seen.
+	return start, nil, nil
+}
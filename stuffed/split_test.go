@@ -0,0 +1,90 @@
+package stuffed_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkSplitFunc(t require.TestingT, r io.Reader, expected []string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	scanner.Split(stuffed.SplitFunc())
+	actual := []string{}
+	for scanner.Scan() {
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.Decode(scanner.Bytes(), &decoded))
+		actual = append(actual, decoded.String())
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, expected, actual)
+}
+
+func TestSplitFunc(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	encoded := encodeList(inputList)
+	checkSplitFunc(t, bytes.NewReader(encoded), inputList)
+
+	// Reading a byte at a time makes sure that we handle delimiters that are
+	// split across reads.
+	checkSplitFunc(t, iotest.OneByteReader(bytes.NewReader(encoded)), inputList)
+
+	// The final delimiter is optional, and extra delimiters are skipped.
+	withoutFinal := encoded[:len(encoded)-len(delimiter)]
+	checkSplitFunc(t, iotest.HalfReader(bytes.NewReader(withoutFinal)), inputList)
+	extra := append(append([]byte{}, delimiter...), encodeList([]string{"abc", "def"})...)
+	extra = append(extra, delimiter...)
+	checkSplitFunc(t, iotest.OneByteReader(bytes.NewReader(extra)), []string{"abc", "def"})
+	checkSplitFunc(t, bytes.NewReader(nil), []string{})
+}
+
+// chunkedReader returns its content in a fixed sequence of reads.
+type chunkedReader struct {
+	chunks []string
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks[0] = r.chunks[0][n:]
+	if len(r.chunks[0]) == 0 {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func TestSplitRecords(t *testing.T) {
+	advance, token, err := stuffed.SplitRecords([]byte("\x03abc\xfe"), false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, advance)
+	assert.Nil(t, token)
+
+	advance, token, err = stuffed.SplitRecords([]byte("\xfe\xfd\x03abc\xfe\xfd\x00"), false)
+	require.NoError(t, err)
+	assert.Equal(t, 6, advance)
+	assert.Equal(t, []byte("\x03abc"), token)
+
+	advance, token, err = stuffed.SplitRecords([]byte("\x03abc\xfe"), true)
+	require.NoError(t, err)
+	assert.Equal(t, 5, advance)
+	assert.Equal(t, []byte("\x03abc\xfe"), token)
+
+	// The delimiter between the two records is split across two reads.
+	r := &chunkedReader{[]string{"\x03abc\xfe", "\xfd\x03def\xfe\xfd"}}
+	scanner := bufio.NewScanner(r)
+	scanner.Split(stuffed.SplitRecords)
+	var tokens []string
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"\x03abc", "\x03def"}, tokens)
+}
@@ -0,0 +1,80 @@
+package stuffed
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	// NonCanonical is the error that is returned by DecodeStrict when a
+	// stuffed record is well-formed, but isn't the encoding that Encode would
+	// produce for its content.
+	NonCanonical = errors.New("Record is not canonically encoded")
+)
+
+// DecodeStrict reads a binary record from an input buffer using the stuffed
+// records encoding, just like Decode, but only accepts the canonical encoding
+// of each record: the one that Encode would produce.  Decode accepts a handful
+// of other encodings of the same content, so if you hash or compare encoded
+// records as identifiers, use DecodeStrict to make sure that each record has
+// exactly one encoding.  We return NonCanonical for any other encoding, and
+// leave the output buffer unchanged.  Specifically, a canonical encoding never
+// contains:
+//
+//   - a run header digit that's larger than the radix (which aliases a smaller
+//     header with a larger high digit),
+//   - a run whose content contains the delimiter, or
+//   - a run that is one byte short of the maximum length, and is followed by
+//     a delimiter.  (Encode only looks for delimiters that start and end
+//     within the maximum run length, so it would produce a full run instead.)
+func DecodeStrict(encoded []byte, record *bytes.Buffer) error {
+	if err := checkCanonical(encoded); err != nil {
+		return err
+	}
+	return Decode(encoded, record)
+}
+
+// checkCanonical verifies that a stuffed record is canonically encoded.
+func checkCanonical(encoded []byte) error {
+	// For the first run, the length is one byte.  (Every possible value of
+	// that byte is canonical.)
+	if len(encoded) < 1 {
+		return io.EOF
+	}
+	runLength := int(encoded[0])
+	encoded = encoded[1:]
+	maxRun := maxInitialRun
+
+	for {
+		if runLength > maxRun {
+			return InvalidRunLength
+		}
+		if len(encoded) < runLength {
+			return io.EOF
+		}
+		if bytes.Index(encoded[:runLength], delimiterBytes) != -1 {
+			return NonCanonical
+		}
+		encoded = encoded[runLength:]
+		if runLength < maxRun {
+			if len(encoded) == 0 {
+				return nil
+			}
+			if runLength == maxRun-1 {
+				return NonCanonical
+			}
+		}
+
+		if len(encoded) < delimiterLength {
+			return io.EOF
+		}
+		low, high := int(encoded[0]), int(encoded[1])
+		encoded = encoded[delimiterLength:]
+		runLength = low + radix*high
+		maxRun = maxRemainingRun
+		if runLength <= maxRun && (low >= radix || high >= radix) {
+			return NonCanonical
+		}
+	}
+}
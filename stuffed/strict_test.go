@@ -0,0 +1,97 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"pgregory.net/rapid"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	for _, tc := range shortTestCases {
+		var buf bytes.Buffer
+		require.NoError(t, stuffed.DecodeStrict([]byte(tc.encoded), &buf))
+		assert.Equal(t, tc.decoded, buf.String())
+	}
+
+	aliases := []string{
+		// The run contains a delimiter.
+		"\x04a\xfe\xfdb",
+		// The second run's header should be "\x01\x01".
+		"\x00\xfe\x00" + strings.Repeat("a", 254),
+		// A 251-byte run followed by a delimiter should be a 252-byte run.
+		"\xfb" + strings.Repeat("a", 251) + "\x01\x00b",
+	}
+	for _, encoded := range aliases {
+		var buf bytes.Buffer
+		require.NoError(t, stuffed.Decode([]byte(encoded), &buf))
+		decoded := buf.String()
+		buf.Reset()
+		assert.Equal(t, stuffed.NonCanonical, stuffed.DecodeStrict([]byte(encoded), &buf))
+		assert.Equal(t, 0, buf.Len())
+
+		var canonical bytes.Buffer
+		stuffed.Encode([]byte(decoded), &canonical)
+		require.NoError(t, stuffed.DecodeStrict(canonical.Bytes(), &buf))
+		assert.Equal(t, decoded, buf.String())
+	}
+
+	var buf bytes.Buffer
+	assert.Equal(t, io.EOF, stuffed.DecodeStrict([]byte("\x03ab"), &buf))
+	assert.Equal(t, stuffed.InvalidRunLength, stuffed.DecodeStrict([]byte("\xff"), &buf))
+}
+
+// runContent generates the content of a run, which is mostly filler, but can
+// start and end with delimiter bytes.
+func runContent(t *rapid.T, length int) []byte {
+	interesting := rapid.SampledFrom([]byte{'a', 0xfe, 0xfd})
+	content := bytes.Repeat([]byte{'a'}, length)
+	for i := 0; i < 3 && i < length; i++ {
+		content[i] = interesting.Draw(t, "head").(byte)
+		content[length-1-i] = interesting.Draw(t, "tail").(byte)
+	}
+	return content
+}
+
+// TestDecodeStrictRandomRecords checks that DecodeStrict accepts a record if
+// and only if re-encoding its content produces the same record.
+func TestDecodeStrictRandomRecords(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		var encoded bytes.Buffer
+		length := rapid.SampledFrom([]int{0, 1, 2, 3, maxInitialRun - 1, maxInitialRun}).Draw(t, "length").(int)
+		encoded.WriteByte(byte(length))
+		encoded.Write(runContent(t, length))
+		runs := rapid.IntRange(0, 3).Draw(t, "runs").(int)
+		for i := 0; i < runs; i++ {
+			length := rapid.SampledFrom([]int{0, 1, 2, 3, 255, maxRemainingRun - 1, maxRemainingRun}).Draw(t, "length").(int)
+			low, high := length%radix, length/radix
+			if high > 0 && low+radix <= 0xff && rapid.Bool().Draw(t, "alias").(bool) {
+				low, high = low+radix, high-1
+			}
+			encoded.WriteByte(byte(low))
+			encoded.WriteByte(byte(high))
+			encoded.Write(runContent(t, length))
+		}
+
+		var decoded, strict bytes.Buffer
+		err := stuffed.Decode(encoded.Bytes(), &decoded)
+		strictErr := stuffed.DecodeStrict(encoded.Bytes(), &strict)
+		if err != nil {
+			assert.Error(t, strictErr)
+			return
+		}
+		var reencoded bytes.Buffer
+		stuffed.Encode(decoded.Bytes(), &reencoded)
+		if bytes.Equal(encoded.Bytes(), reencoded.Bytes()) {
+			require.NoError(t, strictErr)
+			assert.Equal(t, decoded.Bytes(), strict.Bytes())
+		} else {
+			assert.Equal(t, stuffed.NonCanonical, strictErr)
+		}
+	})
+}
@@ -2,6 +2,22 @@
 // encoding.  This is a modified version of Consistent Overhead Byte Stuffing
 // (COBS), which uses the uncommon two-byte sequence `0xfe 0xfd` as the record
 // delimiter, instead of the more common one-byte sequence `0x00`.
+//
+// # Concurrency
+//
+// The package-level functions, and the methods on Codec, are safe to call from
+// multiple goroutines at once, as long as no goroutine modifies a buffer while
+// another is reading it.  A ListInfo, Query, or HashRing can be shared by many
+// goroutines once you've finished building it.  RecentBuffer and Workspace do
+// their own locking, and are safe for concurrent use.  Everything else that
+// holds state between calls (Scanner, Reader, RecordBuilder, BigRecordBuilder,
+// and SortedWriter) must only be used by one goroutine at a time.
+//
+// Internally, functions that need temporary buffers for the length of a single
+// call take them from a sync.Pool.  (Comparators that are used for an entire
+// sort or merge keep their own buffers for as long as they're in use.)  Build
+// with the stuffed_nopool tag to allocate fresh buffers for every call
+// instead.
 package stuffed
 
 import (
@@ -21,6 +37,12 @@ var (
 	// InvalidRunLength is the error that is returned when a stuffed record
 	// containing an invalid length prefix.
 	InvalidRunLength = errors.New("Invalid run length")
+
+	// EmbeddedDelimiter is the error that is returned by DecodeNoDelimiters
+	// when a stuffed record turns out to contain an occurrence of the
+	// delimiter sequence, and by RecordBuilder.AddEncoded when an encoded
+	// record does.
+	EmbeddedDelimiter = errors.New("Record contains an embedded delimiter")
 )
 
 // findDelimiter looks for the delimiter sequence within the first maxRun bytes
@@ -33,19 +55,37 @@ func findDelimiter(record []byte, maxRun int) int {
 	} else {
 		record = record[:maxRun]
 	}
-	result := bytes.Index(record, []byte{delimiter0, delimiter1})
+	result := bytes.Index(record, delimiterBytes)
 	if result == -1 {
 		return maxRun
 	}
 	return result
 }
 
+// MaxEncodedLen returns the largest number of bytes that Encode can produce for
+// an n-byte record, including its run headers but not including a trailing
+// delimiter.  You can use this to grow an output buffer once, before encoding
+// into it.  Embedded delimiters never make the encoding larger, since each one
+// is replaced by a run header of the same size, so the worst case (for which
+// this bound is exact) is a record with no delimiters at all.
+func MaxEncodedLen(n int) int {
+	if n < maxInitialRun {
+		return 1 + n
+	}
+	// A full initial run is always followed by at least one more run header,
+	// and so is each full remaining run.
+	return 1 + n + delimiterLength*((n-maxInitialRun)/maxRemainingRun+1)
+}
+
 // Encode writes a binary record into an output buffer using the stuffed records
 // encoding.  This guarantees that the content that we write does not contain
 // any occurrences of the delimiter.  (We do _not_ write a trailing copy of the
 // delimiter; it is your responsibility to write this in between records using
-// EncodeDelimiter.)
+// EncodeDelimiter.)  Encode keeps no state between calls, so you can call it
+// from many goroutines at once, as long as each one has its own output buffer.
 func Encode(record []byte, buf *bytes.Buffer) {
+	buf.Grow(MaxEncodedLen(len(record)))
+
 	// For the first run, we encode a maximum of 252 characters, so that we can
 	// encode the length in a single byte.
 	runSize := findDelimiter(record, maxInitialRun)
@@ -82,6 +122,59 @@ func Encode(record []byte, buf *bytes.Buffer) {
 	}
 }
 
+// EncodeAppend appends the stuffed records encoding of a binary record to dst
+// and returns the extended slice, following the append-style convention of the
+// standard library.  It produces exactly the same bytes as Encode.  (Just like
+// Encode, we do _not_ append a trailing delimiter; use AppendDelimiter for
+// that.)
+func EncodeAppend(dst []byte, record []byte) []byte {
+	if n := len(dst) + MaxEncodedLen(len(record)); n > cap(dst) {
+		grown := make([]byte, len(dst), n)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	// For the first run, we encode a maximum of 252 characters, so that we can
+	// encode the length in a single byte.
+	runSize := findDelimiter(record, maxInitialRun)
+	dst = append(dst, byte(runSize))
+	dst = append(dst, record[:runSize]...)
+	record = record[runSize:]
+	if runSize < maxInitialRun {
+		// We reached the end (with a virtual terminating delimiter).
+		if len(record) == 0 {
+			return dst
+		}
+
+		// record should start with delimiter, so skip over it.
+		record = record[2:]
+	}
+
+	// For any remaining runs, we encode a maximum of 65008 characters, encoding
+	// the length in two bytes.
+	for {
+		runSize := findDelimiter(record, maxRemainingRun)
+		dst = append(dst, byte(runSize%radix), byte(runSize/radix))
+		dst = append(dst, record[:runSize]...)
+		record = record[runSize:]
+		if runSize < maxRemainingRun {
+			// We reached the end (with a virtual terminating delimiter).
+			if len(record) == 0 {
+				return dst
+			}
+
+			// record should start with delimiter, so skip over it.
+			record = record[2:]
+		}
+	}
+}
+
+// AppendDelimiter appends the stuffed records delimiter to dst and returns the
+// extended slice.
+func AppendDelimiter(dst []byte) []byte {
+	return append(dst, delimiter0, delimiter1)
+}
+
 // EncodeDelimiter writes the stuffed records delimiter to an output buffer.
 // You should use this to separate records in your output stream.
 func EncodeDelimiter(buf *bytes.Buffer) {
@@ -92,7 +185,9 @@ func EncodeDelimiter(buf *bytes.Buffer) {
 // Decode reads a binary record from an input buffer using the stuffed records
 // encoding.  You must ensure that record does not contain any occurrences of
 // the delimiter sequence.  (FindDelimiter can help you find the bounds of an
-// encoded record before decoding it.)
+// encoded record before decoding it.)  Like Encode, Decode is safe for
+// concurrent use; many goroutines can decode from the same input buffer, as
+// long as nothing modifies it.
 func Decode(encoded []byte, record *bytes.Buffer) error {
 	// For the first run, the length is one byte.
 	if len(encoded) < 1 {
@@ -140,16 +235,57 @@ func Decode(encoded []byte, record *bytes.Buffer) error {
 	}
 }
 
+// DecodeAppend decodes a stuffed record, appends its decoded content to dst,
+// and returns the extended slice.  If the record is malformed, we return dst
+// unchanged along with the error.
+func DecodeAppend(dst []byte, encoded []byte) ([]byte, error) {
+	original := dst
+	r := newChunkReader(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return original, err
+		}
+		if !ok {
+			return dst, nil
+		}
+		dst = append(dst, chunk...)
+	}
+}
+
+// DecodeNoDelimiters reads a binary record from an input buffer using the
+// stuffed records encoding, just like Decode, but assumes that the decoded
+// content does not contain any occurrences of the delimiter sequence.  This
+// lets us skip the work of reinserting delimiters between runs.  If the record
+// does contain an embedded delimiter, we return EmbeddedDelimiter.  (The
+// content of record is unspecified in that case.)
+func DecodeNoDelimiters(encoded []byte, record *bytes.Buffer) error {
+	r := newChunkReader(encoded)
+	for {
+		chunk, ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		record.Write(chunk)
+		if r.pendingDelimiter {
+			return EmbeddedDelimiter
+		}
+	}
+}
+
 // FindDelimiter returns the index of the first occurrence of the stuffed
 // records delimiter in buf, or -1 if it doesn't occur.
 func FindDelimiter(record []byte) int {
-	return bytes.Index(record, []byte{delimiter0, delimiter1})
+	return bytes.Index(record, delimiterBytes)
 }
 
 // FindLastDelimiter returns the index of the last occurrence of the stuffed
 // records delimiter in buf, or -1 if it doesn't occur.
 func FindLastDelimiter(record []byte) int {
-	return bytes.LastIndex(record, []byte{delimiter0, delimiter1})
+	return bytes.LastIndex(record, delimiterBytes)
 }
 
 // IsStartOfRecord returns whether a particular offset within a buffer is the
@@ -168,11 +304,45 @@ func IsStartOfRecord(buffer []byte, offset int) bool {
 }
 
 // Scanner iterates through a buffer containing zero or more delimited stuffed
-// records.
+// records.  A Scanner is not safe for concurrent use, since Next updates its
+// position; give each goroutine its own Scanner.  (They can share the
+// underlying buffer.)
 type Scanner struct {
 	record []byte
 	list   []byte
 	buf    bytes.Buffer
+	info   *ListInfo
+	next   int
+
+	// opts describes how the records were encoded.  Like lenient mode, it
+	// stays in effect when you Reset the Scanner.
+	opts options
+
+	// whole is the entire underlying buffer, and start is the offset of the
+	// current record within it.
+	whole []byte
+	start int
+
+	// When sparse is set, we only visit the records at or after each of the
+	// offsets in the offsets slice.
+	sparse  bool
+	offsets []int
+
+	// In lenient mode, we skip over malformed records, keeping track of how
+	// many bytes we've skipped and the most recent error.
+	lenient bool
+	skipped int
+	lastErr error
+}
+
+// NewScanner returns a Scanner that reads from a buffer of delimited stuffed
+// records, configured with some options.  (The zero Scanner, which you Reset
+// yourself, uses the default options.)  The options stay in effect when you
+// Reset the Scanner.
+func NewScanner(encodedList []byte, opts ...Option) *Scanner {
+	s := &Scanner{opts: newOptions(opts)}
+	s.Reset(encodedList)
+	return s
 }
 
 // Reset updates a Scanner to read from a new buffer of delimited stuffed
@@ -181,14 +351,143 @@ func (s *Scanner) Reset(encodedList []byte) {
 	s.record = nil
 	s.list = encodedList
 	s.buf.Reset()
+	s.info = nil
+	s.next = 0
+	s.whole = encodedList
+	s.start = 0
+	s.sparse = false
+	s.offsets = nil
+	s.skipped = 0
+	s.lastErr = nil
+}
+
+// ResetWithInfo updates a Scanner to read from the buffer described by a
+// ListInfo.  The Scanner will use the ListInfo's record table to find each
+// record, instead of searching for delimiters.  The ListInfo must have been
+// computed with the same Codec that the Scanner uses; we panic if it wasn't,
+// since the ListInfo's record boundaries would be wrong.
+func (s *Scanner) ResetWithInfo(info *ListInfo) {
+	if !bytes.Equal(s.opts.codec.delim(), info.codec.delim()) {
+		panic("stuffed: ResetWithInfo called with a ListInfo for a different Codec")
+	}
+	s.Reset(info.Bytes())
+	s.info = info
+}
+
+// ResetWithOffsets updates a Scanner to visit only some of the records in a
+// buffer of delimited stuffed records.  For each offset (which must be in
+// increasing order), the Scanner visits the first record that starts at or
+// after that offset.  It jumps directly to each offset, and so never has to
+// search for delimiters in the gaps between the records that you're interested
+// in.  An offset that falls before the end of a record that we've already
+// visited is skipped, so that each record is visited at most once.
+//
+// This is useful when an external index tells you which parts of a large
+// buffer are worth looking at.  The offsets can point into the middle of a
+// record or delimiter; we'll resynchronize at the next record boundary.
+func (s *Scanner) ResetWithOffsets(encodedList []byte, offsets []int) {
+	s.Reset(encodedList)
+	s.sparse = true
+	s.offsets = offsets
+}
+
+// recordBoundary returns the offset of the first record boundary in a buffer
+// of records separated by delim that is at or after offset.
+func recordBoundary(list, delim []byte, offset int) int {
+	if offset >= len(list) {
+		return len(list)
+	}
+	if offset <= 0 || (offset >= len(delim) && bytes.Equal(list[offset-len(delim):offset], delim)) {
+		return offset
+	}
+	// Start searching early in case offset points into the middle of a
+	// delimiter.
+	searchStart := offset - len(delim) + 1
+	index := bytes.Index(list[searchStart:], delim)
+	if index == -1 {
+		return len(list)
+	}
+	return searchStart + index + len(delim)
+}
+
+// SetLenient controls whether a Scanner tolerates corrupt records.  In lenient
+// mode, Next checks that Decode would accept each record before returning it,
+// including verifying its checksum, limits, and transform, if you've configured
+// any.  If a record is malformed (for instance, because of a torn write), Next
+// skips over it, resynchronizing at the next delimiter, and keeps going.  Use
+// Skipped and LastError to find out whether this has happened.  Lenient mode
+// stays in effect when you Reset the Scanner.
+func (s *Scanner) SetLenient(lenient bool) {
+	s.lenient = lenient
+}
+
+// Skipped returns the number of bytes of malformed records that a lenient
+// Scanner has skipped over since it was last Reset.
+func (s *Scanner) Skipped() int {
+	return s.skipped
+}
+
+// LastError returns the error that caused a lenient Scanner to most recently
+// skip over a malformed record, or nil if it hasn't skipped any since it was
+// last Reset.
+func (s *Scanner) LastError() error {
+	return s.lastErr
 }
 
 // Next returs whether there is a next stuffed record in the underlying buffer.
 // If this returns true, you can use Encoded and Decode to access that record.
 func (s *Scanner) Next() bool {
+	for s.advance() {
+		if !s.lenient {
+			return true
+		}
+		if err := s.opts.validate(s.record); err != nil {
+			s.skipped += len(s.record)
+			s.lastErr = err
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// advance moves to the next record, without checking whether it's well-formed.
+func (s *Scanner) advance() bool {
+	// If we have a record table, just step through it.
+	if s.info != nil {
+		if s.next >= s.info.Len() {
+			return false
+		}
+		s.record = s.info.Record(s.next)
+		s.start = s.info.Offset(s.next)
+		s.next++
+		return true
+	}
+
+	// If we have a list of interesting offsets, jump to the next one that we
+	// haven't already scanned past.
+	if s.sparse {
+		for len(s.offsets) > 0 {
+			offset := s.offsets[0]
+			s.offsets = s.offsets[1:]
+			if offset < len(s.whole)-len(s.list) {
+				continue
+			}
+			s.list = s.whole[recordBoundary(s.whole, s.opts.codec.delim(), offset):]
+			return s.nextRecord()
+		}
+		return false
+	}
+
+	return s.nextRecord()
+}
+
+// nextRecord finds the next record at the start of s.list.
+func (s *Scanner) nextRecord() bool {
 	// Skip over any leading delimiters.
-	for bytes.HasPrefix(s.list, []byte{delimiter0, delimiter1}) {
-		s.list = s.list[delimiterLength:]
+	delim := s.opts.codec.delim()
+	for bytes.HasPrefix(s.list, delim) {
+		s.list = s.list[len(delim):]
 	}
 
 	// If the buffer is now empty, we've reached the end of the list.
@@ -198,7 +497,8 @@ func (s *Scanner) Next() bool {
 
 	// Otherwise, whatever exists at the start of the buffer, up through the
 	// next delimiter, is the next encoded record.
-	index := FindDelimiter(s.list)
+	s.start = len(s.whole) - len(s.list)
+	index := bytes.Index(s.list, delim)
 	if index == -1 {
 		s.record = s.list
 		s.list = nil
@@ -215,9 +515,22 @@ func (s *Scanner) Encoded() []byte {
 	return s.record
 }
 
+// Offset returns the offset of the current stuffed record within the buffer
+// that the Scanner was Reset with.
+func (s *Scanner) Offset() int {
+	return s.start
+}
+
+// EncodedRange returns the start and end offsets of the current stuffed
+// record's encoded content within the buffer that the Scanner was Reset with.
+// (Encoded returns the same range of the buffer as a slice.)
+func (s *Scanner) EncodedRange() (int, int) {
+	return s.start, s.start + len(s.record)
+}
+
 // Decode reads the current stuffed record and decodes it into an output Buffer.
 func (s *Scanner) Decode(decoded *bytes.Buffer) error {
-	return Decode(s.record, decoded)
+	return s.opts.decode(s.record, decoded)
 }
 
 func checkPrefix(chunk, prefix []byte) (int, int) {
@@ -268,8 +581,7 @@ func CompareEncodedPrefix(encoded, prefix []byte) (int, error) {
 			return -1, nil
 		}
 
-		chunk := []byte{0xfe, 0xfd}
-		cmp, consumed := checkPrefix(chunk, prefix)
+		cmp, consumed := checkPrefix(delimiterBytes, prefix)
 		if cmp != 0 {
 			return cmp, nil
 		}
@@ -308,8 +620,7 @@ func CompareEncodedPrefix(encoded, prefix []byte) (int, error) {
 				return -1, nil
 			}
 
-			chunk := []byte{0xfe, 0xfd}
-			cmp, consumed := checkPrefix(chunk, prefix)
+			cmp, consumed := checkPrefix(delimiterBytes, prefix)
 			if cmp != 0 {
 				return cmp, nil
 			}
@@ -318,6 +629,50 @@ func CompareEncodedPrefix(encoded, prefix []byte) (int, error) {
 	}
 }
 
+// CompareEncoded lexicographically compares the decoded content of two stuffed
+// records, returning -1, 0, or 1, just like bytes.Compare.  (You provide the
+// _encoded_ stuffed records, and we walk through their runs in lockstep,
+// without decoding either of them into a buffer.)  We stop as soon as we find a
+// difference, so we only return an error for a malformed record if we reach
+// the malformed part before that.
+func CompareEncoded(a, b []byte) (int, error) {
+	ra := newChunkReader(a)
+	rb := newChunkReader(b)
+	var chunkA, chunkB []byte
+	var okA, okB bool
+	var err error
+	for {
+		chunkA, okA, err = ra.nonEmpty(chunkA)
+		if err != nil {
+			return 0, err
+		}
+		chunkB, okB, err = rb.nonEmpty(chunkB)
+		if err != nil {
+			return 0, err
+		}
+		if !okA || !okB {
+			switch {
+			case okA:
+				return 1, nil
+			case okB:
+				return -1, nil
+			default:
+				return 0, nil
+			}
+		}
+
+		length := len(chunkA)
+		if length > len(chunkB) {
+			length = len(chunkB)
+		}
+		if cmp := bytes.Compare(chunkA[:length], chunkB[:length]); cmp != 0 {
+			return cmp, nil
+		}
+		chunkA = chunkA[length:]
+		chunkB = chunkB[length:]
+	}
+}
+
 // EncodedStartsWith checks whether the decoded content of a stuffed record
 // begins with a prefix.  (You provide the _encoded_ stuffed record, and we
 // perform the check without decoding the content into a buffer.)
@@ -329,17 +684,57 @@ func EncodedStartsWith(encoded, prefix []byte) (bool, error) {
 // FindRecordsWithPrefix takes a buffer containing a list of stuffed
 // records that are sorted by their decoded content, and returns the subset of
 // the buffer containing records whose decoded content starts with a particular
-// prefix.  We do this without decoding any of the records.
+// prefix.  We do this without decoding any of the records.  The result never
+// includes any delimiters before the first matching record or after the last
+// one.  (So for an empty prefix, which every record matches, the result is the
+// entire list with any leading and trailing delimiters trimmed off; we return
+// this immediately, without looking at any records.)
 func FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
+	return findRecordsWithPrefix(encodedList, prefix, delimiterBytes, CompareEncodedPrefix, nil)
+}
+
+// FindRecordsWithPrefixChecked is like FindRecordsWithPrefix, but checks that
+// the records that it looks at are in sorted order.  Whenever we look at a
+// record during the binary search, we also compare it with the record after
+// it.  We also make sure that the records just before and after the result
+// sort before and after the prefix, respectively.  If we find any records that
+// are out of order, we return an ErrUnsorted error, instead of silently
+// returning the wrong result.  (We don't look at every record, so this doesn't
+// guarantee that the entire list is sorted.)  For an empty prefix, which every
+// record matches, we check the same records that the binary search would look
+// at, even though we don't need to search.
+func FindRecordsWithPrefixChecked(encodedList, prefix []byte) ([]byte, error) {
+	return findRecordsWithPrefix(encodedList, prefix, delimiterBytes, CompareEncodedPrefix, CompareEncoded)
+}
+
+// findRecordsWithPrefix implements FindRecordsWithPrefix for a list of records
+// separated by delim, using compare to check whether each encoded record starts
+// with the prefix.  If order is not nil, we use it to verify that each record
+// that we look at sorts before the record after it.
+func findRecordsWithPrefix(encodedList, prefix, delim []byte, compare func(encoded, prefix []byte) (int, error), order func(a, b []byte) (int, error)) ([]byte, error) {
 	// min always points at the beginning of an encoded record.  max always
 	// points at the end of one.
 	min := 0
 	max := len(encodedList)
-	for bytes.HasPrefix(encodedList[min:max], []byte{delimiter0, delimiter1}) {
-		min += delimiterLength
+	for bytes.HasPrefix(encodedList[min:max], delim) {
+		min += len(delim)
+	}
+	for bytes.HasSuffix(encodedList[min:max], delim) {
+		max -= len(delim)
 	}
-	for bytes.HasSuffix(encodedList[min:max], []byte{delimiter0, delimiter1}) {
-		max -= delimiterLength
+
+	// Every record starts with the empty prefix, so there's nothing to search
+	// for.
+	if len(prefix) == 0 {
+		if min >= max {
+			return nil, nil
+		}
+		if order != nil {
+			if err := checkSearchedRecords(encodedList, min, max, delim, order); err != nil {
+				return nil, err
+			}
+		}
+		return encodedList[min:max], nil
 	}
 
 	end := max
@@ -351,14 +746,14 @@ func FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
 		// Jump to the middle of the remainder of the buffer, then find the
 		// start of the enclosing record.
 		mid := (max + min) / 2
-		index := FindLastDelimiter(encodedList[min:mid])
+		index := bytes.LastIndex(encodedList[min:mid], delim)
 		recordStart := min
 		if index != -1 {
-			recordStart += index + delimiterLength
+			recordStart += index + len(delim)
 		}
 
 		// Find the end of the record.
-		index = FindDelimiter(encodedList[recordStart:max])
+		index = bytes.Index(encodedList[recordStart:max], delim)
 		recordEnd := max
 		if index != -1 {
 			recordEnd = recordStart + index
@@ -367,28 +762,33 @@ func FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
 		// Compare this record to the requested prefix.  If it matches, remember
 		// its location, but continue to look for any earlier matching records.
 		record := encodedList[recordStart:recordEnd]
-		cmp, err := CompareEncodedPrefix(record, prefix)
+		cmp, err := compare(record, prefix)
 		if err != nil {
 			return nil, err
 		}
+		if order != nil {
+			if err := checkNextRecord(encodedList[:end], recordStart, recordEnd, delim, order); err != nil {
+				return nil, err
+			}
+		}
 
 		switch cmp {
 		case -1:
 			min = recordEnd
-			for bytes.HasPrefix(encodedList[min:max], []byte{delimiter0, delimiter1}) {
-				min += delimiterLength
+			for bytes.HasPrefix(encodedList[min:max], delim) {
+				min += len(delim)
 			}
 		case 1:
 			max = recordStart
-			for bytes.HasSuffix(encodedList[min:max], []byte{delimiter0, delimiter1}) {
-				max -= delimiterLength
+			for bytes.HasSuffix(encodedList[min:max], delim) {
+				max -= len(delim)
 			}
 		default:
 			earliestMatchStart = recordStart
 			earliestMatchEnd = recordEnd
 			max = recordStart
-			for bytes.HasSuffix(encodedList[min:max], []byte{delimiter0, delimiter1}) {
-				max -= delimiterLength
+			for bytes.HasSuffix(encodedList[min:max], delim) {
+				max -= len(delim)
 			}
 		}
 	}
@@ -398,42 +798,53 @@ func FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
 		return nil, nil
 	}
 
+	if order != nil {
+		if err := checkPreviousRecord(encodedList[:earliestMatchStart], earliestMatchStart, prefix, delim, compare); err != nil {
+			return nil, err
+		}
+	}
+
 	// Once the earliest matching record is found, iterate forward until we find
 	// the first non-matching record.
+	previousRecordStart := earliestMatchStart
 	previousRecordEnd := earliestMatchEnd
 
 	// For the first matching record, avoid repeating the prefix check.
 	nextRecordStart := previousRecordEnd
-	for bytes.HasPrefix(encodedList[nextRecordStart:], []byte{delimiter0, delimiter1}) {
-		nextRecordStart += delimiterLength
+	for bytes.HasPrefix(encodedList[nextRecordStart:], delim) {
+		nextRecordStart += len(delim)
 	}
 
 	// Check the next record to see if it matches the prefix.
 	for nextRecordStart < end {
 		// Find the end of the record.
-		nextRecordEnd := FindDelimiter(encodedList[nextRecordStart:])
+		nextRecordEnd := bytes.Index(encodedList[nextRecordStart:], delim)
 		if nextRecordEnd == -1 {
 			nextRecordEnd = end
 		} else {
 			nextRecordEnd += nextRecordStart
 		}
 
-		matches, err := EncodedStartsWith(encodedList[nextRecordStart:nextRecordEnd], prefix)
+		cmp, err := compare(encodedList[nextRecordStart:nextRecordEnd], prefix)
 		if err != nil {
 			return nil, err
 		}
 
-		if !matches {
+		if cmp == -1 && order != nil {
+			return nil, &ErrUnsorted{First: previousRecordStart, Second: nextRecordStart}
+		}
+		if cmp != 0 {
 			// This is the first record that DOESN'T match.  Our result is
 			// everything up through the previous record.
 			return encodedList[earliestMatchStart:previousRecordEnd], nil
 		}
 
 		// This record matches.  Skip past it to find the next record.
+		previousRecordStart = nextRecordStart
 		previousRecordEnd = nextRecordEnd
 		nextRecordStart = nextRecordEnd
-		for bytes.HasPrefix(encodedList[nextRecordStart:], []byte{delimiter0, delimiter1}) {
-			nextRecordStart += delimiterLength
+		for bytes.HasPrefix(encodedList[nextRecordStart:], delim) {
+			nextRecordStart += len(delim)
 		}
 	}
 
@@ -441,3 +852,80 @@ func FindRecordsWithPrefix(encodedList, prefix []byte) ([]byte, error) {
 	// match.
 	return encodedList[earliestMatchStart:previousRecordEnd], nil
 }
+
+// checkNextRecord verifies that the record at [start, end) in list sorts before
+// the record after it (if there is one), returning ErrUnsorted if it doesn't.
+func checkNextRecord(list []byte, start, end int, delim []byte, order func(a, b []byte) (int, error)) error {
+	nextStart := end
+	for bytes.HasPrefix(list[nextStart:], delim) {
+		nextStart += len(delim)
+	}
+	if nextStart >= len(list) {
+		return nil
+	}
+	nextEnd := len(list)
+	if index := bytes.Index(list[nextStart:], delim); index != -1 {
+		nextEnd = nextStart + index
+	}
+	cmp, err := order(list[start:end], list[nextStart:nextEnd])
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return &ErrUnsorted{First: start, Second: nextStart}
+	}
+	return nil
+}
+
+// checkSearchedRecords verifies that each of the records that
+// findRecordsWithPrefix would look at, when searching list[min:max] for a
+// prefix that every record matches, sorts before the record after it.
+func checkSearchedRecords(list []byte, min, max int, delim []byte, order func(a, b []byte) (int, error)) error {
+	end := max
+	for max > min {
+		mid := (max + min) / 2
+		recordStart := min
+		if index := bytes.LastIndex(list[min:mid], delim); index != -1 {
+			recordStart += index + len(delim)
+		}
+		for bytes.HasPrefix(list[recordStart:max], delim) {
+			recordStart += len(delim)
+		}
+		recordEnd := max
+		if index := bytes.Index(list[recordStart:max], delim); index != -1 {
+			recordEnd = recordStart + index
+		}
+		if err := checkNextRecord(list[:end], recordStart, recordEnd, delim, order); err != nil {
+			return err
+		}
+		max = recordStart
+		for bytes.HasSuffix(list[min:max], delim) {
+			max -= len(delim)
+		}
+	}
+	return nil
+}
+
+// checkPreviousRecord verifies that the last record in list (if there is one),
+// which comes just before the first record that matches prefix, sorts before
+// the prefix, returning ErrUnsorted if it doesn't.
+func checkPreviousRecord(list []byte, matchStart int, prefix, delim []byte, compare func(encoded, prefix []byte) (int, error)) error {
+	for bytes.HasSuffix(list, delim) {
+		list = list[:len(list)-len(delim)]
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	start := 0
+	if index := bytes.LastIndex(list, delim); index != -1 {
+		start = index + len(delim)
+	}
+	cmp, err := compare(list[start:], prefix)
+	if err != nil {
+		return err
+	}
+	if cmp >= 0 {
+		return &ErrUnsorted{First: start, Second: matchStart}
+	}
+	return nil
+}
@@ -98,6 +98,44 @@ func TestDecodeRecords(t *testing.T) {
 	}
 }
 
+func TestMaxEncodedLen(t *testing.T) {
+	for _, n := range []int{0, 1, 251, 252, 253, 1000, 64008 + 252, 64008 + 253, 2*64008 + 252, 200000} {
+		var encoded bytes.Buffer
+		stuffed.Encode(bytes.Repeat([]byte{'a'}, n), &encoded)
+		assert.Equal(t, encoded.Len(), stuffed.MaxEncodedLen(n), "%d", n)
+
+		encoded.Reset()
+		stuffed.Encode(bytes.Repeat([]byte{'a', 0xfe, 0xfd}, n/3), &encoded)
+		assert.True(t, encoded.Len() <= stuffed.MaxEncodedLen(n/3*3), "%d", n)
+	}
+}
+
+func TestDecodedLen(t *testing.T) {
+	for _, tc := range shortTestCases {
+		length, err := stuffed.DecodedLen([]byte(tc.encoded))
+		require.NoError(t, err)
+		assert.Equal(t, len(tc.decoded), length)
+	}
+
+	_, err := stuffed.DecodedLen([]byte("\x03ab"))
+	assert.Equal(t, io.EOF, err)
+	_, err = stuffed.DecodedLen([]byte("\xff"))
+	assert.Equal(t, stuffed.InvalidRunLength, err)
+}
+
+func TestDecodeNoDelimiters(t *testing.T) {
+	for _, tc := range shortTestCases {
+		var buf bytes.Buffer
+		err := stuffed.DecodeNoDelimiters([]byte(tc.encoded), &buf)
+		if strings.Contains(tc.decoded, "\xfe\xfd") {
+			assert.Equal(t, stuffed.EmbeddedDelimiter, err)
+		} else {
+			require.NoError(t, err)
+			assert.Equal(t, string(tc.decoded), buf.String())
+		}
+	}
+}
+
 func ExampleScanner() {
 	encoded := []byte("\x03abc\xfe\xfd\x00\xfe\xfd\xfe\xfd\x041234\xfe\xfd")
 	var s stuffed.Scanner
@@ -246,3 +284,152 @@ func TestFindRecordsWithPrefix(t *testing.T) {
 		checkFindRecordsWithPrefix(t, shortTestCaseInputs(), tc.prefix, tc.expected)
 	}
 }
+
+func TestFindRecordsWithEmptyPrefix(t *testing.T) {
+	encoded := encodeList([]string{"abc", "def"})
+	testCases := []struct {
+		list     string
+		expected string
+	}{
+		{"", ""},
+		{"\xfe\xfd\xfe\xfd", ""},
+		{string(encoded), string(encoded[:len(encoded)-2])},
+		{"\xfe\xfd" + string(encoded) + "\xfe\xfd", string(encoded[:len(encoded)-2])},
+	}
+	for _, tc := range testCases {
+		actual, err := stuffed.FindRecordsWithPrefix([]byte(tc.list), nil)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, string(actual), "%q", tc.list)
+	}
+}
+
+func TestAppendRecords(t *testing.T) {
+	prefix := []byte("prefix")
+	for _, tc := range shortTestCases {
+		encoded := stuffed.EncodeAppend(prefix[:len(prefix):len(prefix)], []byte(tc.decoded))
+		assert.Equal(t, "prefix"+tc.encoded, string(encoded))
+		assert.Equal(t, "prefix"+tc.encoded+"\xfe\xfd", string(stuffed.AppendDelimiter(encoded)))
+
+		decoded, err := stuffed.DecodeAppend(prefix[:len(prefix):len(prefix)], []byte(tc.encoded))
+		require.NoError(t, err)
+		assert.Equal(t, "prefix"+tc.decoded, string(decoded))
+	}
+
+	decoded, err := stuffed.DecodeAppend(prefix, []byte("\x03ab"))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, prefix, decoded)
+}
+
+func TestScannerWithOffsets(t *testing.T) {
+	// Record offsets: "abc" at 0, "def" at 6, "" at 12, "ghi" at 15.
+	encoded := []byte("\x03abc\xfe\xfd\x03def\xfe\xfd\x00\xfe\xfd\x03ghi\xfe\xfd")
+	scan := func(offsets ...int) []string {
+		actual := []string{}
+		var s stuffed.Scanner
+		s.ResetWithOffsets(encoded, offsets)
+		for s.Next() {
+			var decoded bytes.Buffer
+			require.NoError(t, s.Decode(&decoded))
+			actual = append(actual, decoded.String())
+		}
+		return actual
+	}
+
+	assert.Equal(t, []string{}, scan())
+	assert.Equal(t, []string{"abc", "ghi"}, scan(0, 15))
+	assert.Equal(t, []string{"def", ""}, scan(6, 12))
+	// Offsets inside a record or delimiter resynchronize at the next record.
+	assert.Equal(t, []string{"def", "ghi"}, scan(2, 13))
+	assert.Equal(t, []string{"def"}, scan(4))
+	assert.Equal(t, []string{"def"}, scan(5))
+	// Each record is visited at most once.
+	assert.Equal(t, []string{"abc", "def"}, scan(0, 0, 1, 3, 6, 7))
+	assert.Equal(t, []string{}, scan(16, 100))
+}
+
+func TestCompareEncoded(t *testing.T) {
+	inputList := shortTestCaseInputs()
+	inputList = append(inputList, "abc\xfe", "abc\xfe\xfe", "abd", "\xfe\xfe", "\xff")
+	for _, a := range inputList {
+		for _, b := range inputList {
+			var encodedA, encodedB bytes.Buffer
+			stuffed.Encode([]byte(a), &encodedA)
+			stuffed.Encode([]byte(b), &encodedB)
+			cmp, err := stuffed.CompareEncoded(encodedA.Bytes(), encodedB.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, strings.Compare(a, b), cmp, "%q <=> %q", a, b)
+		}
+	}
+
+	_, err := stuffed.CompareEncoded([]byte("\x03abc"), []byte("\x03ab"))
+	assert.Equal(t, io.EOF, err)
+	_, err = stuffed.CompareEncoded([]byte("\xfd"), []byte("\x00"))
+	assert.Equal(t, stuffed.InvalidRunLength, err)
+}
+
+func TestLenientScanner(t *testing.T) {
+	encoded := []byte("\x03abc\xfe\xfd\x05de\xfe\xfd\x03fgh\xfe\xfd\xff\xfe\xfd\x00")
+	scan := func(s *stuffed.Scanner) []string {
+		actual := []string{}
+		for s.Next() {
+			var decoded bytes.Buffer
+			require.NoError(t, s.Decode(&decoded))
+			actual = append(actual, decoded.String())
+		}
+		return actual
+	}
+
+	var s stuffed.Scanner
+	s.SetLenient(true)
+	s.Reset(encoded)
+	assert.Equal(t, []string{"abc", "fgh", ""}, scan(&s))
+	assert.Equal(t, 4, s.Skipped())
+	assert.Equal(t, stuffed.InvalidRunLength, s.LastError())
+
+	// Lenient mode survives a Reset, but the counters don't.
+	s.Reset(encoded[:15])
+	assert.Equal(t, []string{"abc", "fgh"}, scan(&s))
+	assert.Equal(t, 3, s.Skipped())
+	assert.Equal(t, io.EOF, s.LastError())
+
+	s.SetLenient(false)
+	s.Reset(encoded)
+	assert.Equal(t, []string{"\x03abc", "\x05de", "\x03fgh", "\xff", "\x00"}, parseEncoded(&s))
+	assert.Equal(t, 0, s.Skipped())
+	assert.NoError(t, s.LastError())
+}
+
+func parseEncoded(s *stuffed.Scanner) []string {
+	var result []string
+	for s.Next() {
+		result = append(result, string(s.Encoded()))
+	}
+	return result
+}
+
+func TestScannerOffsets(t *testing.T) {
+	encoded := []byte("\xfe\xfd\x03abc\xfe\xfd\xfe\xfd\x00\xfe\xfd\x03def")
+	expected := [][2]int{{2, 6}, {10, 11}, {13, 17}}
+	check := func(s *stuffed.Scanner, expected [][2]int) {
+		var actual [][2]int
+		for s.Next() {
+			start, end := s.EncodedRange()
+			assert.Equal(t, start, s.Offset())
+			assert.Equal(t, s.Encoded(), encoded[start:end])
+			actual = append(actual, [2]int{start, end})
+		}
+		assert.Equal(t, expected, actual)
+	}
+
+	var s stuffed.Scanner
+	s.Reset(encoded)
+	check(&s, expected)
+
+	info, err := stuffed.NewListInfo(encoded)
+	require.NoError(t, err)
+	s.ResetWithInfo(info)
+	check(&s, expected)
+
+	s.ResetWithOffsets(encoded, []int{3, 13})
+	check(&s, expected[1:])
+}
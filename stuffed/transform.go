@@ -0,0 +1,118 @@
+package stuffed
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Transform is a reversible transformation that we apply to the content of each
+// record before stuffing it, and undo after unstuffing it.  (Compression is the
+// most common example.)  Because we stuff the transformed content, the result
+// is still an ordinary stuffed record, so you can still delimit, scan, and
+// resynchronize a list of transformed records without undoing the transform.
+//
+// Note that functions that inspect the encoded content of records directly,
+// such as CompareEncoded and FindRecordsWithPrefix, see the transformed content,
+// not the original content.
+type Transform interface {
+	// Apply transforms the content of a record.  It must not modify record.
+	Apply(record []byte) []byte
+	// Invert undoes Apply, returning an error if transformed wasn't produced
+	// by Apply.  It must not modify transformed, but the result may alias it.
+	// If the result would be longer than max bytes, Invert must return an
+	// ErrRecordTooLarge error, and should do so without producing the entire
+	// result, so that a small, malicious record can't make us allocate a
+	// large amount of memory.
+	Invert(transformed []byte, max int) ([]byte, error)
+	// MaxTransformedLen returns the largest number of bytes that Apply can
+	// produce for an n-byte record.  We use this to decide how large a
+	// transformed record we're willing to read, given a limit on the size of
+	// the original content, so a record that Apply produces must never be
+	// larger than this.
+	MaxTransformedLen(n int) int
+}
+
+// EncodeWithTransform writes a binary record to an output buffer using the
+// stuffed records encoding, after applying a Transform to its content.  Use
+// DecodeWithTransform to decode the record and undo the transform.
+func EncodeWithTransform(record []byte, dest *bytes.Buffer, transform Transform) {
+	Encode(transform.Apply(record), dest)
+}
+
+// DecodeWithTransform reads a binary record that was written by
+// EncodeWithTransform, undoing the transform.  If the record is malformed, or
+// the transform can't be undone, we return an error and leave the output buffer
+// unchanged.  We refuse to produce more than DefaultMaxRecordSize bytes of
+// content; use a Scanner or Reader with WithTransform and WithLimits to choose
+// a different limit.
+func DecodeWithTransform(encoded []byte, record *bytes.Buffer, transform Transform) error {
+	o := options{transform: transform}
+	return o.decode(encoded, record)
+}
+
+// FlateTransform is a Transform that compresses each record using DEFLATE.
+// Each record is compressed separately, so this works best with records that
+// are large, or internally repetitive.  A FlateTransform is safe for concurrent
+// use.
+type FlateTransform struct {
+	level   int
+	writers sync.Pool
+}
+
+// NewFlateTransform creates a new FlateTransform that compresses records using
+// the given compression level, which has the same meaning as in the
+// compress/flate package.
+func NewFlateTransform(level int) (*FlateTransform, error) {
+	w, err := flate.NewWriter(ioutil.Discard, level)
+	if err != nil {
+		return nil, err
+	}
+	t := &FlateTransform{level: level}
+	t.writers.Put(w)
+	return t, nil
+}
+
+// Apply compresses a record.
+func (t *FlateTransform) Apply(record []byte) []byte {
+	var compressed bytes.Buffer
+	w, _ := t.writers.Get().(*flate.Writer)
+	if w == nil {
+		// NewFlateTransform has already checked that the level is valid.
+		w, _ = flate.NewWriter(&compressed, t.level)
+	} else {
+		w.Reset(&compressed)
+	}
+	// Writing to a bytes.Buffer can't fail.
+	w.Write(record)
+	w.Close()
+	// Don't let the pooled writer hold on to the result.
+	w.Reset(ioutil.Discard)
+	t.writers.Put(w)
+	return compressed.Bytes()
+}
+
+// Invert decompresses a record.  We stop decompressing as soon as the result
+// exceeds max bytes.
+func (t *FlateTransform) Invert(compressed []byte, max int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer r.Close()
+	var record bytes.Buffer
+	n, err := record.ReadFrom(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(max) {
+		return nil, &ErrRecordTooLarge{Size: int(n), Limit: max}
+	}
+	return record.Bytes(), nil
+}
+
+// MaxTransformedLen returns the largest number of bytes that Apply can produce
+// for an n-byte record.  This is the same conservative bound that zlib's
+// deflateBound uses, which holds for any compression level.
+func (t *FlateTransform) MaxTransformedLen(n int) int {
+	return n + (n+7)>>3 + (n+63)>>6 + 5
+}
@@ -0,0 +1,187 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlateTransform(t *testing.T) {
+	transform, err := stuffed.NewFlateTransform(flate.BestCompression)
+	require.NoError(t, err)
+
+	var encoded bytes.Buffer
+	for _, input := range shortTestCaseInputs() {
+		stuffed.EncodeWithTransform([]byte(input), &encoded, transform)
+		stuffed.EncodeDelimiter(&encoded)
+	}
+
+	var actual []string
+	var s stuffed.Scanner
+	s.Reset(encoded.Bytes())
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.DecodeWithTransform(s.Encoded(), &decoded, transform))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, shortTestCaseInputs(), actual)
+
+	// Repetitive records should get smaller.
+	record := strings.Repeat("stuffed records ", 1000)
+	encoded.Reset()
+	stuffed.EncodeWithTransform([]byte(record), &encoded, transform)
+	assert.True(t, encoded.Len() < len(record)/10)
+
+	// Corrupted records don't decompress, and leave the output unchanged.
+	var corrupted bytes.Buffer
+	stuffed.Encode([]byte("not compressed"), &corrupted)
+	decoded := bytes.NewBufferString("prefix")
+	assert.Error(t, stuffed.DecodeWithTransform(corrupted.Bytes(), decoded, transform))
+	assert.Equal(t, "prefix", decoded.String())
+
+	_, err = stuffed.NewFlateTransform(100)
+	assert.Error(t, err)
+}
+
+func TestWithTransform(t *testing.T) {
+	transform, err := stuffed.NewFlateTransform(flate.DefaultCompression)
+	require.NoError(t, err)
+	opts := []stuffed.Option{stuffed.WithTransform(transform), stuffed.WithChecksum()}
+
+	inputList := []string{"b", strings.Repeat("c", 1000), "", "a"}
+	rb := stuffed.NewRecordBuilder(opts...)
+	for _, input := range inputList {
+		rb.WriteString(input)
+		rb.FinishRecord()
+	}
+	rb.Sort()
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+	assert.True(t, encoded.Len() < 100)
+
+	var actual []string
+	s := stuffed.NewScanner(encoded.Bytes(), opts...)
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		actual = append(actual, decoded.String())
+	}
+	assert.Equal(t, []string{"", "a", "b", strings.Repeat("c", 1000)}, actual)
+
+	// MaxRecordSize applies to the decompressed content.
+	opts = append(opts, stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 100}))
+	s = stuffed.NewScanner(encoded.Bytes(), opts...)
+	var decoded bytes.Buffer
+	for i := 0; i < 3; i++ {
+		require.True(t, s.Next())
+		require.NoError(t, s.Decode(&decoded))
+	}
+	require.True(t, s.Next())
+	assert.IsType(t, &stuffed.ErrRecordTooLarge{}, s.Decode(&decoded))
+	assert.Equal(t, "ab", decoded.String())
+}
+
+func TestFlateTransformBound(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	for _, level := range []int{flate.HuffmanOnly, flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression} {
+		transform, err := stuffed.NewFlateTransform(level)
+		require.NoError(t, err)
+		for _, n := range []int{0, 1, 100, 1000, 70000} {
+			// Random content doesn't compress, so it's the worst case.
+			record := make([]byte, n)
+			random.Read(record)
+			compressed := transform.Apply(record)
+			assert.True(t, len(compressed) <= transform.MaxTransformedLen(n), "level %d, %d bytes", level, n)
+
+			decompressed, err := transform.Invert(compressed, n)
+			require.NoError(t, err)
+			assert.Equal(t, record, decompressed)
+			if n > 0 {
+				_, err = transform.Invert(compressed, n-1)
+				assert.IsType(t, &stuffed.ErrRecordTooLarge{}, err)
+			}
+		}
+	}
+}
+
+func TestWithTransformAtLimit(t *testing.T) {
+	transform, err := stuffed.NewFlateTransform(flate.BestSpeed)
+	require.NoError(t, err)
+	random := rand.New(rand.NewSource(0))
+	var inputs []string
+	for i := 0; i < 3; i++ {
+		record := make([]byte, 1000)
+		random.Read(record)
+		inputs = append(inputs, string(record))
+	}
+	checkOptionsRoundTrip(t, inputs, stuffed.WithTransform(transform), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 1000}))
+}
+
+// expandTransform is a Transform that turns each byte of the original content
+// into 1000 copies of itself, to simulate a decompression bomb.
+type expandTransform struct {
+	maxInvert *int
+}
+
+func (t expandTransform) Apply(record []byte) []byte {
+	return record
+}
+
+func (t expandTransform) Invert(transformed []byte, max int) ([]byte, error) {
+	*t.maxInvert = max
+	return bytes.Repeat(transformed, 1000), nil
+}
+
+func (t expandTransform) MaxTransformedLen(n int) int {
+	return n
+}
+
+func TestWithTransformBomb(t *testing.T) {
+	var maxInvert int
+	transform := expandTransform{&maxInvert}
+	var encoded bytes.Buffer
+	stuffed.Encode([]byte("0123456789"), &encoded)
+
+	// The limit is passed along to Invert, and checked afterwards, in case
+	// the transform doesn't.
+	s := stuffed.NewScanner(encoded.Bytes(), stuffed.WithTransform(transform), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 50}))
+	require.True(t, s.Next())
+	var decoded bytes.Buffer
+	assert.IsType(t, &stuffed.ErrRecordTooLarge{}, s.Decode(&decoded))
+	assert.Equal(t, 50, maxInvert)
+
+	// Records whose transformed content is too large aren't inverted at all.
+	maxInvert = 0
+	s = stuffed.NewScanner(encoded.Bytes(), stuffed.WithTransform(transform), stuffed.WithLimits(stuffed.Limits{MaxRecordSize: 5}))
+	require.True(t, s.Next())
+	assert.IsType(t, &stuffed.ErrRecordTooLarge{}, s.Decode(&decoded))
+	assert.Equal(t, 0, maxInvert)
+
+	// Without any limits, we use DefaultMaxRecordSize.
+	require.NoError(t, stuffed.DecodeWithTransform(encoded.Bytes(), &decoded, transform))
+	assert.Equal(t, stuffed.DefaultMaxRecordSize, maxInvert)
+	assert.Equal(t, 10000, decoded.Len())
+}
+
+func TestWithTransformWarnings(t *testing.T) {
+	transform, err := stuffed.NewFlateTransform(flate.BestCompression)
+	require.NoError(t, err)
+	var encoded bytes.Buffer
+	stuffed.EncodeWithTransform([]byte(strings.Repeat("a", 90)), &encoded, transform)
+
+	var warnings []stuffed.LimitWarning
+	limits := stuffed.Limits{MaxRecordSize: 100, WarnFraction: 0.8, OnWarning: func(warning stuffed.LimitWarning) {
+		warnings = append(warnings, warning)
+	}}
+	s := stuffed.NewScanner(encoded.Bytes(), stuffed.WithTransform(transform), stuffed.WithLimits(limits))
+	require.True(t, s.Next())
+	var decoded bytes.Buffer
+	require.NoError(t, s.Decode(&decoded))
+	assert.Equal(t, []stuffed.LimitWarning{{Limit: "MaxRecordSize", Used: 90, Max: 100}}, warnings)
+}
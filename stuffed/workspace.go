@@ -0,0 +1,113 @@
+package stuffed
+
+import (
+	"sort"
+	"sync"
+)
+
+// Workspace holds many named lists of stuffed records, each sorted by its
+// decoded content, and answers queries across all of them.  We compute a
+// ListInfo for each list when you add it, so that each query only has to
+// binary search each list's record table.  A Workspace is safe for concurrent
+// use; you can add and remove lists while other goroutines are querying.
+type Workspace struct {
+	mu      sync.RWMutex
+	sources map[string]*ListInfo
+	names   []string
+}
+
+// WorkspaceResult is a record returned by a Workspace query, along with the
+// name of the list that it came from.
+type WorkspaceResult struct {
+	Source  string
+	Encoded []byte
+}
+
+// NewWorkspace creates a new, empty Workspace.
+func NewWorkspace() *Workspace {
+	return &Workspace{sources: make(map[string]*ListInfo)}
+}
+
+// Add adds a sorted list of stuffed records to the workspace, replacing any
+// existing list with the same name.  Returns an error (and leaves the
+// workspace unchanged) if any of the records are malformed.  The workspace
+// refers to encodedList, so you must not modify it afterwards; to change a
+// list, Add a new buffer under the same name.
+func (w *Workspace) Add(name string, encodedList []byte) error {
+	info, err := NewListInfo(encodedList)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.sources[name]; !ok {
+		i := sort.SearchStrings(w.names, name)
+		w.names = append(w.names, "")
+		copy(w.names[i+1:], w.names[i:])
+		w.names[i] = name
+	}
+	w.sources[name] = info
+	return nil
+}
+
+// Remove removes a list from the workspace.  It's not an error to remove a
+// list that isn't there.
+func (w *Workspace) Remove(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.sources[name]; !ok {
+		return
+	}
+	delete(w.sources, name)
+	i := sort.SearchStrings(w.names, name)
+	w.names = append(w.names[:i], w.names[i+1:]...)
+}
+
+// Names returns the names of the lists in the workspace, in sorted order.
+func (w *Workspace) Names() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]string(nil), w.names...)
+}
+
+// Query runs a query against every list in the workspace, using
+// Query.ExecuteWithInfo.  The results are grouped by list, in order of the
+// lists' names.  The query's Limit and Desc fields apply to each list
+// separately.
+func (w *Workspace) Query(q *Query) ([]WorkspaceResult, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var result []WorkspaceResult
+	for _, name := range w.names {
+		records, err := q.ExecuteWithInfo(w.sources[name])
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			result = append(result, WorkspaceResult{Source: name, Encoded: record})
+		}
+	}
+	return result, nil
+}
+
+// FindRecordsWithPrefix returns the records in every list in the workspace
+// whose decoded content starts with prefix.
+func (w *Workspace) FindRecordsWithPrefix(prefix []byte) ([]WorkspaceResult, error) {
+	return w.Query(&Query{Prefix: prefix})
+}
+
+// Lookup returns the records in every list in the workspace whose decoded
+// content is exactly key.
+func (w *Workspace) Lookup(key []byte) ([]WorkspaceResult, error) {
+	// key followed by a NUL byte is the smallest string that sorts after key.
+	end := append(append([]byte(nil), key...), 0x00)
+	return w.Query(&Query{Prefix: key, Range: KeyRange{Start: key, End: end}})
+}
+
+// Range returns the records in every list in the workspace whose decoded
+// content is in the range [start, end).  A nil start or end means that that
+// side of the range is unbounded.
+func (w *Workspace) Range(start, end []byte) ([]WorkspaceResult, error) {
+	return w.Query(&Query{Range: KeyRange{Start: start, End: end}})
+}
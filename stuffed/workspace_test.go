@@ -0,0 +1,61 @@
+package stuffed_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type workspaceResult struct {
+	source, decoded string
+}
+
+func decodeWorkspaceResults(t *testing.T, results []stuffed.WorkspaceResult) []workspaceResult {
+	actual := []workspaceResult{}
+	for _, result := range results {
+		var decoded bytes.Buffer
+		require.NoError(t, stuffed.Decode(result.Encoded, &decoded))
+		actual = append(actual, workspaceResult{result.Source, decoded.String()})
+	}
+	return actual
+}
+
+func TestWorkspace(t *testing.T) {
+	w := stuffed.NewWorkspace()
+	require.NoError(t, w.Add("b.go", encodeList([]string{"bar", "foo", "foobar"})))
+	require.NoError(t, w.Add("a.go", encodeList([]string{"baz", "foo", "fop"})))
+	require.NoError(t, w.Add("c.go", encodeList([]string{"qux"})))
+	assert.Error(t, w.Add("d.go", []byte("\x03ab")))
+	assert.Equal(t, []string{"a.go", "b.go", "c.go"}, w.Names())
+
+	results, err := w.FindRecordsWithPrefix([]byte("fo"))
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceResult{
+		{"a.go", "foo"}, {"a.go", "fop"},
+		{"b.go", "foo"}, {"b.go", "foobar"},
+	}, decodeWorkspaceResults(t, results))
+
+	results, err = w.Lookup([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceResult{{"a.go", "foo"}, {"b.go", "foo"}}, decodeWorkspaceResults(t, results))
+
+	results, err = w.Range([]byte("bar"), []byte("baz"))
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceResult{{"b.go", "bar"}}, decodeWorkspaceResults(t, results))
+
+	results, err = w.Query(&stuffed.Query{Range: stuffed.KeyRange{Start: []byte("f")}, Limit: 1, Desc: true})
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceResult{{"a.go", "fop"}, {"b.go", "foobar"}, {"c.go", "qux"}}, decodeWorkspaceResults(t, results))
+
+	// Replacing and removing lists.
+	require.NoError(t, w.Add("b.go", encodeList([]string{"fob"})))
+	w.Remove("a.go")
+	w.Remove("missing.go")
+	assert.Equal(t, []string{"b.go", "c.go"}, w.Names())
+	results, err = w.FindRecordsWithPrefix([]byte("fo"))
+	require.NoError(t, err)
+	assert.Equal(t, []workspaceResult{{"b.go", "fob"}}, decodeWorkspaceResults(t, results))
+}
@@ -0,0 +1,24 @@
+package example_test
+
+import (
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffedgen/internal/example"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColor(t *testing.T) {
+	for key, expected := range map[string]string{
+		"black":         "#000000",
+		"rebeccapurple": "#663399",
+		"white":         "#ffffff",
+	} {
+		actual, ok := example.Color(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, expected, string(actual), key)
+	}
+	for _, key := range []string{"", "re", "rebecca", "yellow", "red\t#ff0000"} {
+		_, ok := example.Color(key)
+		assert.False(t, ok, key)
+	}
+}
@@ -0,0 +1,5 @@
+// Package example contains a lookup table that's generated by stuffedgen, to
+// make sure that the generated code compiles and works.
+package example
+
+//go:generate go run github.com/dcreager/stuffed-records-go/cmd/stuffedgen -package example -func Color -o colors_table.go colors.txt
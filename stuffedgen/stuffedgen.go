@@ -0,0 +1,170 @@
+// Package stuffedgen generates Go source files that embed a static lookup
+// table of stuffed records, so that small read-only tables can be baked
+// directly into a binary.
+//
+// Each record in the table is a key/value pair: the key, followed by a
+// separator byte, followed by the value.  The records must be sorted, and each
+// key can only appear once.  The generated file contains the encoded list of
+// records, along with a function that looks up the value for a key using
+// stuffed.FindRecordsWithPrefix.
+//
+// The stuffedgen command (in cmd/stuffedgen) wraps this package, and is meant
+// to be called from a go:generate directive.
+package stuffedgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+var (
+	// MissingSeparator is the error that is returned when a record doesn't
+	// contain a separator between its key and value.
+	MissingSeparator = errors.New("Record doesn't contain a key separator")
+
+	// UnsortedKeys is the error that is returned when the records in a table
+	// aren't sorted, or when a key appears more than once.
+	UnsortedKeys = errors.New("Keys are not sorted and unique")
+
+	// UnsupportedEncoding is the error that is returned when a RecordBuilder
+	// doesn't use the default encoding, which the generated lookup function
+	// relies on.
+	UnsupportedEncoding = errors.New("Unsupported record encoding")
+)
+
+// Config controls the contents of a generated file.
+type Config struct {
+	// Package is the name of the package that the generated file belongs to.
+	Package string
+	// Func is the name of the generated lookup function.  Defaults to
+	// "Lookup".
+	Func string
+	// Var is the name of the generated variable that holds the encoded
+	// records.  Defaults to the name of the lookup function, with "Table"
+	// appended, and the first letter lower-cased.
+	Var string
+	// Separator is the byte that separates each record's key from its value.
+	Separator byte
+	// Generator is the name of the program that generated the file, which is
+	// mentioned in the "DO NOT EDIT" comment.  Defaults to "stuffedgen".
+	Generator string
+}
+
+func (c Config) withDefaults() Config {
+	if c.Func == "" {
+		c.Func = "Lookup"
+	}
+	if c.Var == "" {
+		c.Var = string(bytes.ToLower([]byte(c.Func[:1]))) + c.Func[1:] + "Table"
+	}
+	if c.Generator == "" {
+		c.Generator = "stuffedgen"
+	}
+	return c
+}
+
+// bytesPerLine is how many bytes of the encoded table we put on each line of
+// the generated file, so that changes to the table produce readable diffs.
+const bytesPerLine = 32
+
+// Generate writes a Go source file to w that embeds the records in rb, along
+// with a lookup function for them.  rb must already be sorted; we return
+// UnsortedKeys if it isn't.  rb must also use the default encoding, since the
+// lookup function uses the package-level stuffed functions; we return
+// UnsupportedEncoding if it doesn't.
+func Generate(w io.Writer, rb *stuffed.RecordBuilder, config Config) error {
+	if !rb.DefaultEncoding() {
+		return UnsupportedEncoding
+	}
+	config = config.withDefaults()
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+	if err := checkKeys(encoded.Bytes(), config.Separator); err != nil {
+		return err
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by %s. DO NOT EDIT.\n\n", config.Generator)
+	fmt.Fprintf(&src, "package %s\n\n", config.Package)
+	fmt.Fprintf(&src, "import (\n\t\"bytes\"\n\n\t\"github.com/dcreager/stuffed-records-go/stuffed\"\n)\n\n")
+	fmt.Fprintf(&src, "// %s holds the encoded records that %s searches.\n", config.Var, config.Func)
+	fmt.Fprintf(&src, "var %s = []byte(\"\"", config.Var)
+	table := encoded.Bytes()
+	for len(table) > 0 {
+		n := bytesPerLine
+		if n > len(table) {
+			n = len(table)
+		}
+		fmt.Fprintf(&src, " +\n\t%s", strconv.Quote(string(table[:n])))
+		table = table[n:]
+	}
+	fmt.Fprintf(&src, ")\n\n")
+	fmt.Fprintf(&src, lookupTemplate, config.Func, config.Var, rune(config.Separator))
+
+	// This also catches any names in the config that aren't valid identifiers.
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// lookupTemplate is the source of the generated lookup function.  Its
+// arguments are the function name, the variable name, and the separator.
+const lookupTemplate = `// %[1]s returns the value for a key, and whether the key is present.
+func %[1]s(key string) ([]byte, bool) {
+	prefix := append([]byte(key), %[3]q)
+	matching, err := stuffed.FindRecordsWithPrefix(%[2]s, prefix)
+	if err != nil || len(matching) == 0 {
+		return nil, false
+	}
+	var s stuffed.Scanner
+	s.Reset(matching)
+	s.Next()
+	var decoded bytes.Buffer
+	if err := s.Decode(&decoded); err != nil {
+		return nil, false
+	}
+	return decoded.Bytes()[len(prefix):], true
+}
+`
+
+// checkKeys verifies that every record in an encoded list contains a separator,
+// that the records are sorted (which FindRecordsWithPrefix requires), and that
+// the keys are unique.  In a sorted list, all of the records with the same key
+// are next to each other, so we only have to compare each key with the
+// previous one.
+func checkKeys(encodedList []byte, separator byte) error {
+	var s stuffed.Scanner
+	var decoded, previous bytes.Buffer
+	first := true
+	s.Reset(encodedList)
+	for s.Next() {
+		decoded.Reset()
+		if err := s.Decode(&decoded); err != nil {
+			return err
+		}
+		record := decoded.Bytes()
+		end := bytes.IndexByte(record, separator)
+		if end == -1 {
+			return MissingSeparator
+		}
+		if !first {
+			previousKey := previous.Bytes()[:bytes.IndexByte(previous.Bytes(), separator)]
+			if bytes.Compare(previous.Bytes(), record) > 0 || bytes.Equal(previousKey, record[:end]) {
+				return UnsortedKeys
+			}
+		}
+		first = false
+		previous.Reset()
+		previous.Write(record)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package stuffedgen_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/dcreager/stuffed-records-go/stuffedgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerateExample checks that the generated code in the example package is
+// up to date.
+func TestGenerateExample(t *testing.T) {
+	input, err := os.Open("internal/example/colors.txt")
+	require.NoError(t, err)
+	defer input.Close()
+	var rb stuffed.RecordBuilder
+	require.NoError(t, stuffed.IngestLines(input, &rb, 0))
+	rb.Sort()
+
+	var generated bytes.Buffer
+	config := stuffedgen.Config{Package: "example", Func: "Color", Separator: '\t'}
+	require.NoError(t, stuffedgen.Generate(&generated, &rb, config))
+	expected, err := ioutil.ReadFile("internal/example/colors_table.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), generated.String())
+}
+
+func generate(records []string, sort bool, config stuffedgen.Config) error {
+	var rb stuffed.RecordBuilder
+	for _, record := range records {
+		rb.WriteString(record)
+		rb.FinishRecord()
+	}
+	if sort {
+		rb.Sort()
+	}
+	var generated bytes.Buffer
+	return stuffedgen.Generate(&generated, &rb, config)
+}
+
+func TestGenerateErrors(t *testing.T) {
+	config := stuffedgen.Config{Package: "tables"}
+	assert.NoError(t, generate(nil, true, config))
+	assert.NoError(t, generate([]string{"b\x00", "a\x00x", "a!\x00y"}, true, config))
+	assert.Equal(t, stuffedgen.UnsortedKeys, generate([]string{"b\x00", "a\x00"}, false, config))
+	assert.Equal(t, stuffedgen.UnsortedKeys, generate([]string{"a\x00x", "a\x00y"}, true, config))
+	assert.Equal(t, stuffedgen.MissingSeparator, generate([]string{"a\x00", "b"}, true, config))
+
+	// A separator that sorts after some of the bytes in the keys.
+	config.Separator = '='
+	assert.NoError(t, generate([]string{"a=1", "a!=2", "b=3"}, true, config))
+
+	var generated bytes.Buffer
+	rb := stuffed.NewRecordBuilder(stuffed.WithChecksum())
+	assert.Equal(t, stuffedgen.UnsupportedEncoding, stuffedgen.Generate(&generated, rb, config))
+
+	assert.Error(t, generate([]string{"a="}, true, stuffedgen.Config{Package: "not a package"}))
+	assert.Error(t, generate([]string{"a\x00"}, true, stuffedgen.Config{Package: "tables", Func: "123"}))
+}
@@ -0,0 +1,80 @@
+package stuffedlog
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// maxBufferSize is the largest encoded record that an Iterator will buffer.
+// This is comfortably larger than the encoded size of a record of
+// stuffed.DefaultMaxRecordSize bytes.
+const maxBufferSize = 2 * stuffed.DefaultMaxRecordSize
+
+// Iterator reads the records in a log, one at a time.  A record that isn't
+// followed by a delimiter is torn, and is skipped instead of being returned.
+type Iterator struct {
+	scanner *bufio.Scanner
+	decoded bytes.Buffer
+	err     error
+	torn    bool
+}
+
+// NewIterator creates a new Iterator that reads log records from r.
+func NewIterator(r io.Reader) *Iterator {
+	it := &Iterator{scanner: bufio.NewScanner(r)}
+	it.scanner.Buffer(nil, maxBufferSize)
+	it.scanner.Split(it.split)
+	return it
+}
+
+// split wraps stuffed.SplitRecords, dropping the torn record (if any) at the
+// end of the stream.
+func (it *Iterator) split(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := stuffed.SplitRecords(data, atEOF)
+	// SplitRecords only consumes the entire buffer when it returns the last,
+	// undelimited record in the stream.
+	if err == nil && token != nil && advance == len(data) {
+		it.torn = true
+		return advance, nil, nil
+	}
+	return advance, token, err
+}
+
+// Next returns whether there is a next record in the log.  If this returns
+// true, you can use Record to access that record.  If it returns false, you
+// should check Err to see whether we reached the end of the log or encountered
+// an error.
+func (it *Iterator) Next() bool {
+	if it.err != nil || !it.scanner.Scan() {
+		return false
+	}
+	it.decoded.Reset()
+	if err := stuffed.Decode(it.scanner.Bytes(), &it.decoded); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+// Record returns the decoded content of the current record.  The result is
+// only valid until the next call to Next.
+func (it *Iterator) Record() []byte {
+	return it.decoded.Bytes()
+}
+
+// Err returns the first error that we encountered while reading the log.  A
+// torn record at the end of the log is not an error.
+func (it *Iterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.scanner.Err()
+}
+
+// Torn returns whether we skipped over a torn record at the end of the log.
+func (it *Iterator) Torn() bool {
+	return it.torn
+}
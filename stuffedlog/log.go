@@ -0,0 +1,141 @@
+// Package stuffedlog implements a crash-safe, append-only log file of stuffed
+// records.
+//
+// Each record is written to the file followed by a delimiter, in a single
+// write.  A record only counts as part of the log once its delimiter has been
+// written, so if the process crashes in the middle of an append, the torn
+// record at the end of the file is ignored when reading, and removed the next
+// time the log is opened.
+package stuffedlog
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// tailBlockSize is how much of the file we read at a time when searching
+// backwards for the last delimiter.
+const tailBlockSize = 64 << 10
+
+// delimiterLength is the length of the stuffed records delimiter.
+const delimiterLength = 2
+
+// Log is an append-only log file of stuffed records.  A Log is not safe for
+// concurrent use by multiple goroutines.
+type Log struct {
+	file *os.File
+	buf  bytes.Buffer
+	// size is the size of the file, as of the end of the last successful
+	// append.
+	size int64
+	// err, if not nil, is the error that caused a failed append to leave part
+	// of a record in the file.  We refuse any further appends.
+	err error
+}
+
+// Open opens the log file at path, creating it if it doesn't exist.  If the
+// file ends with a torn record (one that isn't followed by a delimiter), we
+// truncate the file to remove it.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	end, err := lastRecordEnd(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if end != info.Size() {
+		if err := file.Truncate(end); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &Log{file: file, size: end}, nil
+}
+
+// lastRecordEnd returns the offset just past the last delimiter in the first
+// size bytes of r, or 0 if there isn't one.
+func lastRecordEnd(r io.ReaderAt, size int64) (int64, error) {
+	block := make([]byte, tailBlockSize+1)
+	end := size
+	for end > 0 {
+		start := end - tailBlockSize
+		if start < 0 {
+			start = 0
+		}
+		// Read one extra byte past the end of the block, so that we find a
+		// delimiter that straddles two blocks.
+		limit := end + 1
+		if limit > size {
+			limit = size
+		}
+		chunk := block[:limit-start]
+		if _, err := r.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		if index := stuffed.FindLastDelimiter(chunk); index != -1 {
+			return start + int64(index) + delimiterLength, nil
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// Append adds a record to the end of the log.  The record isn't guaranteed to
+// be durable until you call Sync.  We refuse records larger than
+// stuffed.DefaultMaxRecordSize, since an Iterator wouldn't be able to read
+// them back.
+//
+// If the write fails, we truncate the file to remove any part of the record
+// that was written, so that the next append doesn't corrupt the log.  If we
+// can't do that, the Log is unusable, and every later Append returns the same
+// error.
+func (l *Log) Append(record []byte) error {
+	if l.err != nil {
+		return l.err
+	}
+	if len(record) > stuffed.DefaultMaxRecordSize {
+		return &stuffed.ErrRecordTooLarge{Size: len(record), Limit: stuffed.DefaultMaxRecordSize}
+	}
+	l.buf.Reset()
+	stuffed.Encode(record, &l.buf)
+	stuffed.EncodeDelimiter(&l.buf)
+	if _, err := l.file.Write(l.buf.Bytes()); err != nil {
+		if truncErr := l.file.Truncate(l.size); truncErr != nil {
+			l.err = err
+		}
+		return err
+	}
+	l.size += int64(l.buf.Len())
+	return nil
+}
+
+// Sync commits the log's contents to stable storage.
+func (l *Log) Sync() error {
+	return l.file.Sync()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Records returns an Iterator over the records that have been appended to the
+// log so far.
+func (l *Log) Records() (*Iterator, error) {
+	info, err := l.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewIterator(io.NewSectionReader(l.file, 0, info.Size())), nil
+}
@@ -0,0 +1,124 @@
+package stuffedlog_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/dcreager/stuffed-records-go/stuffedlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLog(t *testing.T, l *stuffedlog.Log) ([]string, bool) {
+	it, err := l.Records()
+	require.NoError(t, err)
+	return readIterator(t, it)
+}
+
+func readIterator(t *testing.T, it *stuffedlog.Iterator) ([]string, bool) {
+	records := []string{}
+	for it.Next() {
+		records = append(records, string(it.Record()))
+	}
+	require.NoError(t, it.Err())
+	return records, it.Torn()
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stuffedlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	// Large records span the blocks that we read when looking for the end of
+	// the last record.
+	large := strings.Repeat("a", 100000) + "\xfe\xfd" + strings.Repeat("b", 100000)
+	inputs := []string{"abc", "", "\xfe\xfd", large, "def"}
+
+	l, err := stuffedlog.Open(path)
+	require.NoError(t, err)
+	for _, input := range inputs {
+		require.NoError(t, l.Append([]byte(input)))
+	}
+	require.NoError(t, l.Sync())
+	records, torn := readLog(t, l)
+	assert.Equal(t, inputs, records)
+	assert.False(t, torn)
+	require.NoError(t, l.Close())
+
+	// Simulate a crash in the middle of appending a record.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = file.Write([]byte("\x05gh"))
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	records, torn = readIterator(t, stuffedlog.NewIterator(bytes.NewReader(contents)))
+	assert.Equal(t, inputs, records)
+	assert.True(t, torn)
+
+	// Reopening the log removes the torn record.
+	l, err = stuffedlog.Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+	require.NoError(t, l.Append([]byte("ghi")))
+	records, torn = readLog(t, l)
+	assert.Equal(t, append(inputs, "ghi"), records)
+	assert.False(t, torn)
+}
+
+func TestTornOnlyRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stuffedlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("\x03abc"), 0644))
+
+	l, err := stuffedlog.Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+	records, torn := readLog(t, l)
+	assert.Equal(t, []string{}, records)
+	assert.False(t, torn)
+	require.NoError(t, l.Append([]byte("def")))
+	records, _ = readLog(t, l)
+	assert.Equal(t, []string{"def"}, records)
+}
+
+func TestAppendErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stuffedlog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	l, err := stuffedlog.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Append([]byte("abc")))
+	tooLarge := make([]byte, stuffed.DefaultMaxRecordSize+1)
+	assert.Equal(t, &stuffed.ErrRecordTooLarge{Size: len(tooLarge), Limit: stuffed.DefaultMaxRecordSize}, l.Append(tooLarge))
+	records, _ := readLog(t, l)
+	assert.Equal(t, []string{"abc"}, records)
+
+	// We can't write to (or truncate) a closed file, so the log is unusable
+	// from now on.
+	require.NoError(t, l.Close())
+	err = l.Append([]byte("def"))
+	assert.Error(t, err)
+	assert.Equal(t, err, l.Append([]byte("ghi")))
+}
+
+func TestIteratorErrors(t *testing.T) {
+	it := stuffedlog.NewIterator(bytes.NewReader([]byte("\x03abc\xfe\xfd\xff\xfe\xfd\x03def\xfe\xfd")))
+	assert.True(t, it.Next())
+	assert.Equal(t, "abc", string(it.Record()))
+	assert.False(t, it.Next())
+	assert.Error(t, it.Err())
+	assert.False(t, it.Next())
+}
@@ -0,0 +1,114 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// blockHandle describes one block of a table.
+type blockHandle struct {
+	offset, length int64
+	firstKey       []byte
+}
+
+// Reader reads records from a table.  It loads the table's index into memory
+// when it's opened, and reads blocks from the underlying io.ReaderAt as
+// needed.  A Reader is safe for concurrent use if its io.ReaderAt is.
+type Reader struct {
+	r      io.ReaderAt
+	blocks []blockHandle
+}
+
+// Open opens the table contained in the first size bytes of r.
+func Open(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < footerLength {
+		return nil, InvalidTable
+	}
+	var footer [footerLength]byte
+	if _, err := r.ReadAt(footer[:], size-footerLength); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(footer[16:], magic) {
+		return nil, InvalidTable
+	}
+	indexOffset := binary.LittleEndian.Uint64(footer[0:8])
+	indexLength := binary.LittleEndian.Uint64(footer[8:16])
+	if indexOffset > uint64(size-footerLength) || indexLength > uint64(size-footerLength)-indexOffset {
+		return nil, InvalidTable
+	}
+
+	index := make([]byte, indexLength)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+
+	var blocks []blockHandle
+	var s stuffed.Scanner
+	s.Reset(index)
+	for s.Next() {
+		var decoded bytes.Buffer
+		if err := s.Decode(&decoded); err != nil {
+			return nil, err
+		}
+		entry := decoded.Bytes()
+		offset, n := binary.Uvarint(entry)
+		if n <= 0 {
+			return nil, InvalidTable
+		}
+		entry = entry[n:]
+		length, n := binary.Uvarint(entry)
+		if n <= 0 || offset > indexOffset || length > indexOffset-offset {
+			return nil, InvalidTable
+		}
+		blocks = append(blocks, blockHandle{int64(offset), int64(length), entry[n:]})
+	}
+	return &Reader{r: r, blocks: blocks}, nil
+}
+
+// Blocks returns the number of blocks in the table.
+func (tr *Reader) Blocks() int {
+	return len(tr.blocks)
+}
+
+// FindRecordsWithPrefix returns a list of delimited stuffed records containing
+// each record in the table whose decoded content starts with prefix, in sorted
+// order.  We binary search the index to find the blocks that might contain
+// matching records, and only read those blocks.
+func (tr *Reader) FindRecordsWithPrefix(prefix []byte) ([]byte, error) {
+	// The first block that can contain a match is the last one whose first key
+	// sorts before the prefix.  (Any earlier block's records all sort before
+	// that block's first key.)
+	start := sort.Search(len(tr.blocks), func(i int) bool {
+		return bytes.Compare(tr.blocks[i].firstKey, prefix) >= 0
+	})
+	if start > 0 {
+		start--
+	}
+
+	var result bytes.Buffer
+	for i := start; i < len(tr.blocks); i++ {
+		handle := tr.blocks[i]
+		// Once a block's first key sorts after the prefix without matching
+		// it, that block and every later one can't contain any matches.
+		if i > start && !bytes.HasPrefix(handle.firstKey, prefix) {
+			break
+		}
+		block := make([]byte, handle.length)
+		if _, err := tr.r.ReadAt(block, handle.offset); err != nil {
+			return nil, err
+		}
+		matching, err := stuffed.FindRecordsWithPrefix(block, prefix)
+		if err != nil {
+			return nil, err
+		}
+		if len(matching) > 0 {
+			result.Write(matching)
+			stuffed.EncodeDelimiter(&result)
+		}
+	}
+	return result.Bytes(), nil
+}
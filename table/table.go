@@ -0,0 +1,40 @@
+// Package table implements sorted, immutable tables of stuffed records, in the
+// style of an SSTable.
+//
+// A table consists of a sequence of blocks, followed by an index and a footer.
+// Each block is a sorted list of delimited stuffed records.  The index is
+// another list of delimited stuffed records, one per block, each containing the
+// block's offset and length (as uvarints) followed by the first record in the
+// block.  The footer contains the offset and length of the index (as
+// little-endian 64-bit integers), followed by a magic number.
+//
+// The blocks let a Reader load only the parts of a table that it needs; the
+// index lets it find those blocks with a binary search; and within each block,
+// it uses stuffed.FindRecordsWithPrefix to find the matching records.
+package table
+
+import (
+	"errors"
+)
+
+var (
+	// InvalidTable is the error that is returned when a table's footer or
+	// index is malformed.
+	InvalidTable = errors.New("Invalid table")
+
+	// UnsupportedEncoding is the error that is returned when you try to add
+	// records to a table from a RecordBuilder that doesn't use the default
+	// encoding.
+	UnsupportedEncoding = errors.New("Unsupported record encoding")
+)
+
+// magic identifies the footer of a table.
+var magic = []byte("stuffTBL")
+
+// footerLength is the size of a table's footer: the index offset and length,
+// and the magic number.
+const footerLength = 8 + 8 + 8
+
+// DefaultBlockSize is the block size that NewWriter uses if you don't provide
+// one.
+const DefaultBlockSize = 4096
@@ -0,0 +1,101 @@
+package table_test
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+	"github.com/dcreager/stuffed-records-go/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseStrings(t *testing.T, encoded []byte) []string {
+	result := []string{}
+	var s stuffed.Scanner
+	s.Reset(encoded)
+	for s.Next() {
+		var decoded bytes.Buffer
+		require.NoError(t, s.Decode(&decoded))
+		result = append(result, decoded.String())
+	}
+	return result
+}
+
+func buildTable(t *testing.T, inputList []string, blockSize int) *table.Reader {
+	var rb stuffed.RecordBuilder
+	for _, input := range inputList {
+		rb.WriteString(input)
+		rb.FinishRecord()
+	}
+	rb.Sort()
+
+	var buf bytes.Buffer
+	tw := table.NewWriter(&buf, blockSize)
+	require.NoError(t, tw.AddRecords(&rb))
+	require.NoError(t, tw.Close())
+
+	tr, err := table.Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	return tr
+}
+
+func TestTable(t *testing.T) {
+	var inputList []string
+	for i := 0; i < 500; i++ {
+		inputList = append(inputList, fmt.Sprintf("key%03d\xfe\xfd", i))
+	}
+	inputList = append(inputList, "", "a", "z", strings.Repeat("m", 10000))
+	sorted := append([]string{}, inputList...)
+	sort.Strings(sorted)
+
+	for _, blockSize := range []int{0, 1, 100} {
+		tr := buildTable(t, inputList, blockSize)
+		if blockSize == 1 {
+			assert.Equal(t, len(inputList), tr.Blocks())
+		}
+
+		for _, prefix := range []string{"", "key", "key1", "key25", "key499\xfe", "a", "m", "z", "zz", "0"} {
+			var expected []string
+			for _, input := range sorted {
+				if strings.HasPrefix(input, prefix) {
+					expected = append(expected, input)
+				}
+			}
+			if expected == nil {
+				expected = []string{}
+			}
+			matching, err := tr.FindRecordsWithPrefix([]byte(prefix))
+			require.NoError(t, err)
+			assert.Equal(t, expected, parseStrings(t, matching), "block size %d, prefix %q", blockSize, prefix)
+		}
+	}
+}
+
+func TestEmptyTable(t *testing.T) {
+	tr := buildTable(t, nil, 0)
+	assert.Equal(t, 0, tr.Blocks())
+	matching, err := tr.FindRecordsWithPrefix(nil)
+	require.NoError(t, err)
+	assert.Empty(t, matching)
+}
+
+func TestTableErrors(t *testing.T) {
+	tw := table.NewWriter(&bytes.Buffer{}, 0)
+	require.NoError(t, tw.Add([]byte("b")))
+	assert.Equal(t, stuffed.OutOfOrder, tw.Add([]byte("a")))
+	assert.Equal(t, table.UnsupportedEncoding, tw.AddRecords(stuffed.NewRecordBuilder(stuffed.WithChecksum())))
+
+	_, err := table.Open(bytes.NewReader([]byte("short")), 5)
+	assert.Equal(t, table.InvalidTable, err)
+	footer := make([]byte, 24)
+	_, err = table.Open(bytes.NewReader(footer), 24)
+	assert.Equal(t, table.InvalidTable, err)
+	copy(footer[16:], "stuffTBL")
+	footer[8] = 1
+	_, err = table.Open(bytes.NewReader(footer), 24)
+	assert.Equal(t, table.InvalidTable, err)
+}
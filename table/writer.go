@@ -0,0 +1,132 @@
+package table
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/dcreager/stuffed-records-go/stuffed"
+)
+
+// Writer writes a table of stuffed records to an io.Writer.  You must add
+// records in sorted order; call Close once you've added them all to write the
+// table's index and footer.
+type Writer struct {
+	w         io.Writer
+	blockSize int
+	block     bytes.Buffer
+	sorted    *stuffed.SortedWriter
+	firstKey  []byte
+	index     bytes.Buffer
+	entry     []byte
+	offset    uint64
+}
+
+// NewWriter creates a new Writer that writes a table to w.  We start a new
+// block whenever the current one reaches blockSize encoded bytes; if blockSize
+// is not positive, we use DefaultBlockSize.
+func NewWriter(w io.Writer, blockSize int) *Writer {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	tw := &Writer{w: w, blockSize: blockSize}
+	tw.sorted = stuffed.NewSortedWriter(&tw.block)
+	return tw
+}
+
+// Add adds a record to the table.  If the record sorts before the previous
+// record, we return stuffed.OutOfOrder.
+func (tw *Writer) Add(record []byte) error {
+	if err := tw.sorted.WriteRecord(record); err != nil {
+		return err
+	}
+	if tw.firstKey == nil {
+		tw.firstKey = append([]byte{}, record...)
+	}
+	return tw.finishRecord()
+}
+
+// AddRecords adds all of the records in a RecordBuilder to the table.  You must
+// call the RecordBuilder's Sort method first (unless you added the records in
+// sorted order).  We copy each record's encoded content into the table as-is,
+// without decoding it and re-encoding it, so the RecordBuilder must use the
+// default encoding; if it doesn't, we return UnsupportedEncoding.
+func (tw *Writer) AddRecords(rb *stuffed.RecordBuilder) error {
+	if !rb.DefaultEncoding() {
+		return UnsupportedEncoding
+	}
+	var encoded bytes.Buffer
+	rb.Encode(&encoded)
+	var s stuffed.Scanner
+	s.Reset(encoded.Bytes())
+	for s.Next() {
+		if err := tw.sorted.WriteEncoded(s.Encoded()); err != nil {
+			return err
+		}
+		if tw.firstKey == nil {
+			// We only need to decode the first record in each block.
+			var decoded bytes.Buffer
+			if err := s.Decode(&decoded); err != nil {
+				return err
+			}
+			tw.firstKey = append([]byte{}, decoded.Bytes()...)
+		}
+		if err := tw.finishRecord(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishRecord starts a new block if the current one is full.
+func (tw *Writer) finishRecord() error {
+	if tw.block.Len() >= tw.blockSize {
+		return tw.flush()
+	}
+	return nil
+}
+
+// flush writes out the current block, and adds it to the index.
+func (tw *Writer) flush() error {
+	if tw.block.Len() == 0 {
+		return nil
+	}
+	if _, err := tw.w.Write(tw.block.Bytes()); err != nil {
+		return err
+	}
+
+	tw.entry = tw.entry[:0]
+	tw.entry = appendUvarint(tw.entry, tw.offset)
+	tw.entry = appendUvarint(tw.entry, uint64(tw.block.Len()))
+	tw.entry = append(tw.entry, tw.firstKey...)
+	stuffed.Encode(tw.entry, &tw.index)
+	stuffed.EncodeDelimiter(&tw.index)
+
+	tw.offset += uint64(tw.block.Len())
+	tw.block.Reset()
+	tw.firstKey = nil
+	return nil
+}
+
+func appendUvarint(dst []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], x)
+	return append(dst, buf[:n]...)
+}
+
+// Close writes out the last block, the index, and the footer.  It does not
+// close the underlying io.Writer.
+func (tw *Writer) Close() error {
+	if err := tw.flush(); err != nil {
+		return err
+	}
+	var footer [footerLength]byte
+	binary.LittleEndian.PutUint64(footer[0:8], tw.offset)
+	binary.LittleEndian.PutUint64(footer[8:16], uint64(tw.index.Len()))
+	copy(footer[16:], magic)
+	if _, err := tw.w.Write(tw.index.Bytes()); err != nil {
+		return err
+	}
+	_, err := tw.w.Write(footer[:])
+	return err
+}